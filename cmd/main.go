@@ -48,6 +48,9 @@ import (
 
 	"github.com/kubevirt/ipam-extensions/pkg/config"
 	"github.com/kubevirt/ipam-extensions/pkg/ipamclaimswebhook"
+	"github.com/kubevirt/ipam-extensions/pkg/ips"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
 	"github.com/kubevirt/ipam-extensions/pkg/vminetworkscontroller"
 	"github.com/kubevirt/ipam-extensions/pkg/vmnetworkscontroller"
 	//+kubebuilder:scaffold:imports
@@ -75,6 +78,9 @@ func main() {
 	var tlsMinVersionRaw string
 	var tlsCipherSuitesRaw string
 	var tlsCurvePreferencesRaw string
+	var readUserDefinedNetworks bool
+	var vmiResolutionPolicyName string
+	var defaultNetworkRequestersRaw string
 
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. "+
@@ -84,6 +90,18 @@ func main() {
 	flag.StringVar(&certDir, "certificates-dir", "", "Specify the certificates directory for the webhook server")
 	flag.StringVar(&defaultNetworkNadNamespace, "default-network-nad-namespace", "ovn-kubernetes",
 		"Define the namespace where the NAD to override the default network is located")
+	flag.BoolVar(&readUserDefinedNetworks, "read-user-defined-networks", false,
+		"If set, the primary network configuration is read from the OVN-Kubernetes UserDefinedNetwork and "+
+			"ClusterUserDefinedNetwork objects, falling back to the NADs when those are not installed")
+	flag.StringVar(&vmiResolutionPolicyName, "vmi-resolution-policy",
+		string(ipamclaimswebhook.VMIResolutionPolicyStrict),
+		"How the launcher pods whose VMI cannot be resolved are admitted: 'strict' rejects them, 'best-effort' admits "+
+			"them without requesting persistent IPs. Namespaces may override it with the "+
+			config.VMIResolutionPolicyAnnotation+" annotation")
+	flag.StringVar(&defaultNetworkRequestersRaw, "default-network-annotation-allowlist", "",
+		"Comma-separated list of the identities allowed to create launcher pods already carrying the "+
+			config.MultusDefaultNetAnnotation+" annotation, which is then merged with the VM primary network "+
+			"requests. Each identity is either user:<name>, group:<name> or serviceaccount:<namespace>/<name>")
 	flag.StringVar(&tlsMinVersionRaw, "tls-min-version", "VersionTLS13", `Minimum TLS version
 Supported values are tls package constants names (e.g. VersionTLS12)
 please see https://pkg.go.dev/crypto/tls#pkg-constants.`,
@@ -120,6 +138,18 @@ please see https://pkg.go.dev/crypto/tls#CurveID`,
 		os.Exit(1)
 	}
 
+	vmiResolutionPolicy, err := ipamclaimswebhook.ParseVMIResolutionPolicy(vmiResolutionPolicyName)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the VMI resolution policy")
+		os.Exit(1)
+	}
+
+	defaultNetworkRequesters, err := ipamclaimswebhook.ParseIdentityAllowlist(defaultNetworkRequestersRaw)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the default network annotation allowlist")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -151,6 +181,8 @@ please see https://pkg.go.dev/crypto/tls#CurveID`,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "71d89df3",
 		WebhookServer:          webhookServer,
+		// the user defined networks are read as unstructured objects; serve them from the cache as well
+		Client: client.Options{Cache: &client.CacheOptions{Unstructured: readUserDefinedNetworks}},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -188,12 +220,40 @@ please see https://pkg.go.dev/crypto/tls#CurveID`,
 		os.Exit(1)
 	}
 
+	ctx := ctrl.SetupSignalHandler()
+
+	eventRecorder := mgr.GetEventRecorderFor(ipamclaimswebhook.EventSource)
+	nadConfigs := nads.NewConfigCache(nads.WithInvalidConfigReporter(nads.NewInvalidConfigEventReporter(eventRecorder)))
+	if err := nads.SetupIndexes(ctx, mgr.GetFieldIndexer(), nadConfigs); err != nil {
+		setupLog.Error(err, "unable to set up the NAD indexes")
+		os.Exit(1)
+	}
+	if err := nads.SetupEventHandler(ctx, mgr.GetCache(), nadConfigs); err != nil {
+		setupLog.Error(err, "unable to set up the NAD config cache event handler")
+		os.Exit(1)
+	}
+
+	var primaryNetworkFinderOpts []udn.FinderOption
+	if readUserDefinedNetworks {
+		setupLog.Info("reading the primary network configuration from the user defined networks")
+		primaryNetworkFinderOpts = append(primaryNetworkFinderOpts,
+			udn.WithUserDefinedNetworks(),
+			udn.WithInvalidNetworkReporter(udn.NewInvalidNetworkEventReporter(eventRecorder)),
+		)
+	}
+	primaryNetworks := udn.NewPrimaryNetworkFinder(nadConfigs, primaryNetworkFinderOpts...)
+
+	if err := ips.SetupIndexes(ctx, mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up the IP indexes")
+		os.Exit(1)
+	}
+
 	if err = vmnetworkscontroller.NewVMReconciler(mgr).Setup(); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "VirtualMachine")
 		os.Exit(1)
 	}
 
-	if err = vminetworkscontroller.NewVMIReconciler(mgr).Setup(); err != nil {
+	if err = vminetworkscontroller.NewVMIReconciler(mgr, nadConfigs, primaryNetworks).Setup(); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "VirtualMachineInstance")
 		os.Exit(1)
 	}
@@ -207,12 +267,25 @@ please see https://pkg.go.dev/crypto/tls#CurveID`,
 		&webhook.Admission{
 			Handler: ipamclaimswebhook.NewIPAMClaimsValet(
 				mgr,
+				nadConfigs,
+				primaryNetworks,
 				ipamclaimswebhook.WithDefaultNetNADNamespace(defaultNetworkNadNamespace),
-			)},
+				ipamclaimswebhook.WithVMIResolutionPolicy(vmiResolutionPolicy),
+				ipamclaimswebhook.WithDefaultNetworkRequesters(defaultNetworkRequesters),
+			),
+			WithContextFunc: ipamclaimswebhook.WithRequestDeadline,
+		},
+	)
+
+	mgr.GetWebhookServer().Register(
+		"/validate-kubevirt-io-v1-ip-requests",
+		&webhook.Admission{
+			Handler: ipamclaimswebhook.NewIPRequestsValidator(mgr, nadConfigs, primaryNetworks),
+		},
 	)
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -2,14 +2,19 @@ package claims
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
 
@@ -27,6 +32,81 @@ var (
 	rfc1123SubdomainsRegexp = regexp.MustCompile(rfc1123SubdomainsPattern)
 )
 
+// ErrLeakedIPAMClaim reports an existing IPAMClaim which is not owned by the VM (or VMI) it is named after
+var ErrLeakedIPAMClaim = errors.New("failed since it found an existing IPAMClaim")
+
+// NewIPAMClaim returns the IPAMClaim persisting the IPs of the VMI logical network on the network: it is owned by
+// ownerInfo, labeled as belonging to the VM, and protected by the finalizer released once the VM is gone
+func NewIPAMClaim(
+	vmi *virtv1.VirtualMachineInstance,
+	ownerInfo metav1.OwnerReference,
+	logicalNetworkName string,
+	networkName string,
+) *ipamclaimsapi.IPAMClaim {
+	return &ipamclaimsapi.IPAMClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            ComposeKey(vmi.Name, logicalNetworkName),
+			Namespace:       vmi.Namespace,
+			OwnerReferences: []metav1.OwnerReference{ownerInfo},
+			Finalizers:      []string{KubevirtVMFinalizer},
+			Labels:          OwnedByVMLabel(vmi.Name),
+		},
+		Spec: ipamclaimsapi.IPAMClaimSpec{
+			Network: networkName,
+		},
+	}
+}
+
+// OwnerReferenceFor returns the owner reference of the VMI IPAMClaims: the VM, or the VMI when it has no VM
+func OwnerReferenceFor(vmi *virtv1.VirtualMachineInstance, vm *virtv1.VirtualMachine) metav1.OwnerReference {
+	var obj client.Object
+	gvk := virtv1.VirtualMachineInstanceGroupVersionKind
+	if vm != nil {
+		obj = vm
+		gvk = virtv1.VirtualMachineGroupVersionKind
+	} else {
+		obj = vmi
+	}
+
+	return metav1.OwnerReference{
+		APIVersion:         gvk.GroupVersion().String(),
+		Kind:               gvk.Kind,
+		Name:               obj.GetName(),
+		UID:                obj.GetUID(),
+		Controller:         ptr.To(true),
+		BlockOwnerDeletion: ptr.To(true),
+	}
+}
+
+// Ensure creates the IPAMClaim, unless a claim with the same owner already exists - e.g. created by a concurrent VMI
+// reconcile or launcher pod admission. An existing claim with another owner is reported as ErrLeakedIPAMClaim.
+func Ensure(
+	ctx context.Context,
+	writer client.Writer,
+	reader client.Reader,
+	ipamClaim *ipamclaimsapi.IPAMClaim,
+	opts ...client.CreateOption,
+) error {
+	err := writer.Create(ctx, ipamClaim, opts...)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existingIPAMClaim := &ipamclaimsapi.IPAMClaim{}
+	if err := reader.Get(ctx, client.ObjectKeyFromObject(ipamClaim), existingIPAMClaim); err != nil {
+		return fmt.Errorf("failed to read the existing IPAMClaim %q, let us be on the safe side and retry later: %w",
+			ipamClaim.Name, err)
+	}
+
+	ownerUID := ipamClaim.OwnerReferences[0].UID
+	if len(existingIPAMClaim.OwnerReferences) != 1 || existingIPAMClaim.OwnerReferences[0].UID != ownerUID {
+		return fmt.Errorf("%w for %q", ErrLeakedIPAMClaim, ipamClaim.Name)
+	}
+	log.FromContext(ctx).V(1).Info("found existing IPAMClaim belonging to this VM/VMI, nothing to do",
+		"claim", ipamClaim.Name, "UID", ownerUID)
+	return nil
+}
+
 func Cleanup(c client.Client, vmiKey apitypes.NamespacedName) error {
 	ipamClaims := &ipamclaimsapi.IPAMClaimList{}
 	listOpts := []client.ListOption{
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// pluginConfList holds the fields of a CNI conflist needed to locate the relevant plugin configuration
+type pluginConfList struct {
+	Name    string            `json:"name"`
+	Plugins []json.RawMessage `json:"plugins,omitempty"`
+}
+
+type pluginType struct {
+	Type string `json:"type"`
+}
+
+// pluginConfig is the configuration of the CNI plugin providing the relevant configuration of a NAD
+type pluginConfig struct {
+	raw     json.RawMessage
+	cniType string
+	// confListName is the name of the conflist holding the plugin, since the plugins of a conflist are not required
+	// to carry the network name; empty for a single plugin configuration
+	confListName string
+}
+
+// relevantPluginConfig returns the NAD spec itself when it is a single plugin configuration; out of a conflist, it
+// returns the first plugin isRelevant selects or, when there is none, the first plugin, since the chained plugins
+// following it (e.g. tuning, bandwidth) do not provide IPAM.
+func relevantPluginConfig(nadSpec string, isRelevant func(cniType string) bool) (*pluginConfig, error) {
+	confList := &pluginConfList{}
+	if err := json.Unmarshal([]byte(nadSpec), confList); err != nil {
+		return nil, err
+	}
+	if len(confList.Plugins) == 0 {
+		pluginType := &pluginType{}
+		if err := json.Unmarshal([]byte(nadSpec), pluginType); err != nil {
+			return nil, err
+		}
+		return &pluginConfig{raw: json.RawMessage(nadSpec), cniType: pluginType.Type}, nil
+	}
+
+	var plugins []*pluginConfig
+	for i, plugin := range confList.Plugins {
+		pluginType := &pluginType{}
+		if err := json.Unmarshal(plugin, pluginType); err != nil {
+			return nil, fmt.Errorf("failed to read the conflist plugin #%d: %w", i, err)
+		}
+		plugins = append(plugins, &pluginConfig{raw: plugin, cniType: pluginType.Type, confListName: confList.Name})
+	}
+	for _, plugin := range plugins {
+		if isRelevant(plugin.cniType) {
+			return plugin, nil
+		}
+	}
+	return plugins[0], nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Interpreter extracts the relevant configuration out of the configuration of a CNI plugin
+type Interpreter func(pluginConfig []byte) (*RelevantConfig, error)
+
+// InterpreterRegistry holds the interpreters of the CNI plugin configurations, keyed by the CNI plugin type.
+// The plugins whose type has no registered interpreter are read with the OVN-Kubernetes layout.
+type InterpreterRegistry struct {
+	interpreters       map[string]Interpreter
+	defaultInterpreter Interpreter
+}
+
+var defaultInterpreters = NewInterpreterRegistry()
+
+// NewInterpreterRegistry returns a registry holding the OVN-Kubernetes interpreter, and the IPAM block
+// interpreter for the reference CNI plugins.
+func NewInterpreterRegistry() *InterpreterRegistry {
+	registry := &InterpreterRegistry{
+		interpreters:       map[string]Interpreter{},
+		defaultInterpreter: InterpretOVNKubernetesConfig,
+	}
+	registry.Register(OVNKubernetesCNIType, InterpretOVNKubernetesConfig)
+	for _, cniType := range []string{"bridge", "macvlan", "ipvlan", "host-device"} {
+		registry.Register(cniType, InterpretIPAMBlockConfig)
+	}
+	return registry
+}
+
+// Register sets the interpreter of the CNI plugin type; it is not safe to register interpreters concurrently
+// with extracting configurations.
+func (r *InterpreterRegistry) Register(cniType string, interpreter Interpreter) {
+	r.interpreters[cniType] = interpreter
+}
+
+// Config extracts the relevant configuration out of a NAD spec, either a single plugin configuration or a
+// conflist; out of a conflist, the first plugin having a registered interpreter is read.
+func (r *InterpreterRegistry) Config(nadSpec string) (*RelevantConfig, error) {
+	if nadSpec == "" {
+		return &RelevantConfig{}, nil
+	}
+
+	pluginConfig, err := relevantPluginConfig(nadSpec, r.isRegistered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract CNI configuration from NAD: %w", err)
+	}
+	netConfig, err := r.interpreterFor(pluginConfig.cniType)(pluginConfig.raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract CNI configuration from NAD: %w", err)
+	}
+	if pluginConfig.confListName != "" {
+		netConfig.Name = pluginConfig.confListName
+	}
+	return netConfig, nil
+}
+
+func (r *InterpreterRegistry) isRegistered(cniType string) bool {
+	_, isRegistered := r.interpreters[cniType]
+	return isRegistered
+}
+
+func (r *InterpreterRegistry) interpreterFor(cniType string) Interpreter {
+	if interpreter, isRegistered := r.interpreters[cniType]; isRegistered {
+		return interpreter
+	}
+	return r.defaultInterpreter
+}
+
+// InterpretOVNKubernetesConfig reads the OVN-Kubernetes layout, where the relevant keys are at the plugin top level
+func InterpretOVNKubernetesConfig(pluginConfig []byte) (*RelevantConfig, error) {
+	netConfig := &RelevantConfig{}
+	if err := json.Unmarshal(pluginConfig, netConfig); err != nil {
+		return nil, err
+	}
+	return netConfig, nil
+}
+
+// ipamBlockPluginConfig is the layout of the plugins delegating the IPAM to an ipam sub-object
+// (e.g. whereabouts), holding either a single range or a list of ranges.
+type ipamBlockPluginConfig struct {
+	Name string      `json:"name"`
+	Role NetworkRole `json:"role,omitempty"`
+	IPAM *ipamBlock  `json:"ipam,omitempty"`
+}
+
+type ipamBlock struct {
+	AllowPersistentIPs bool `json:"allowPersistentIPs,omitempty"`
+	ipamRange
+	IPRanges []ipamRange `json:"ipRanges,omitempty"`
+}
+
+type ipamRange struct {
+	Range   string   `json:"range,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	Gateway string   `json:"gateway,omitempty"`
+}
+
+// InterpretIPAMBlockConfig reads the generic IPAM block layout, where the persistence, subnets, excluded ranges and
+// gateways are set in the plugin ipam sub-object
+func InterpretIPAMBlockConfig(pluginConfig []byte) (*RelevantConfig, error) {
+	ipamConfig := &ipamBlockPluginConfig{}
+	if err := json.Unmarshal(pluginConfig, ipamConfig); err != nil {
+		return nil, err
+	}
+	netConfig := &RelevantConfig{Name: ipamConfig.Name, Role: ipamConfig.Role}
+	if ipamConfig.IPAM == nil {
+		return netConfig, nil
+	}
+
+	var subnets, excludeSubnets, gateways []string
+	for _, ipRange := range append([]ipamRange{ipamConfig.IPAM.ipamRange}, ipamConfig.IPAM.IPRanges...) {
+		if ipRange.Range != "" {
+			subnets = append(subnets, ipRange.Range)
+		}
+		excludeSubnets = append(excludeSubnets, ipRange.Exclude...)
+		if ipRange.Gateway != "" {
+			gateways = append(gateways, ipRange.Gateway)
+		}
+	}
+	netConfig.AllowPersistentIPs = ipamConfig.IPAM.AllowPersistentIPs
+	netConfig.Subnets = strings.Join(subnets, ",")
+	netConfig.ExcludeSubnets = strings.Join(excludeSubnets, ",")
+	netConfig.Gateways = strings.Join(gateways, ",")
+	return netConfig, nil
+}
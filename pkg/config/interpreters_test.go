@@ -0,0 +1,88 @@
+package config_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+var _ = Describe("Interpreter registry", func() {
+	DescribeTable("extracts the relevant configuration", func(nadSpec string, expectedConfig *config.RelevantConfig) {
+		Expect(config.NewInterpreterRegistry().Config(nadSpec)).To(Equal(expectedConfig))
+	},
+		Entry("out of an OVN-Kubernetes plugin with excluded subnets",
+			`{"name": "net1", "type": "ovn-k8s-cni-overlay", "allowPersistentIPs": true,
+			  "subnets": "10.0.0.0/24,fd12:1234::/64", "excludeSubnets": "10.0.0.1/32"}`,
+			&config.RelevantConfig{
+				Name:               "net1",
+				AllowPersistentIPs: true,
+				Subnets:            "10.0.0.0/24,fd12:1234::/64",
+				ExcludeSubnets:     "10.0.0.1/32",
+			},
+		),
+		Entry("out of a plugin with an IPAM block holding a single range",
+			`{"name": "net1", "type": "bridge", "ipam": {"type": "whereabouts", "allowPersistentIPs": true,
+			  "range": "10.0.0.0/24", "exclude": ["10.0.0.1/32", "10.0.0.2/32"]}}`,
+			&config.RelevantConfig{
+				Name:               "net1",
+				AllowPersistentIPs: true,
+				Subnets:            "10.0.0.0/24",
+				ExcludeSubnets:     "10.0.0.1/32,10.0.0.2/32",
+			},
+		),
+		Entry("out of a plugin with an IPAM block holding several ranges",
+			`{"name": "net1", "type": "macvlan", "ipam": {"type": "whereabouts", "ipRanges": [
+			  {"range": "10.0.0.0/24", "exclude": ["10.0.0.1/32"]}, {"range": "fd12:1234::/64"}]}}`,
+			&config.RelevantConfig{
+				Name:           "net1",
+				Subnets:        "10.0.0.0/24,fd12:1234::/64",
+				ExcludeSubnets: "10.0.0.1/32",
+			},
+		),
+		Entry("out of a plugin with an IPAM block setting the gateways",
+			`{"name": "net1", "type": "bridge", "ipam": {"type": "whereabouts", "range": "10.0.0.0/24",
+			  "gateway": "10.0.0.254", "ipRanges": [{"range": "fd12:1234::/64", "gateway": "fd12:1234::1"}]}}`,
+			&config.RelevantConfig{
+				Name:     "net1",
+				Subnets:  "10.0.0.0/24,fd12:1234::/64",
+				Gateways: "10.0.0.254,fd12:1234::1",
+			},
+		),
+		Entry("out of a plugin without an IPAM block", `{"name": "net1", "type": "bridge"}`,
+			&config.RelevantConfig{Name: "net1"},
+		),
+		Entry("out of the IPAM block plugin of a conflist",
+			`{"name": "net1", "plugins": [
+			  {"type": "bridge", "ipam": {"allowPersistentIPs": true, "range": "10.0.0.0/24"}},
+			  {"type": "tuning"}
+			]}`,
+			&config.RelevantConfig{Name: "net1", AllowPersistentIPs: true, Subnets: "10.0.0.0/24"},
+		),
+	)
+
+	It("extracts the relevant configuration with the interpreter registered for the plugin type", func() {
+		registry := config.NewInterpreterRegistry()
+		registry.Register("custom-cni", func(pluginConfig []byte) (*config.RelevantConfig, error) {
+			customConfig := struct {
+				NetworkName string `json:"networkName"`
+				Sticky      bool   `json:"stickyIPs"`
+			}{}
+			if err := json.Unmarshal(pluginConfig, &customConfig); err != nil {
+				return nil, err
+			}
+			return &config.RelevantConfig{Name: customConfig.NetworkName, AllowPersistentIPs: customConfig.Sticky}, nil
+		})
+
+		Expect(registry.Config(`{"type": "custom-cni", "networkName": "net1", "stickyIPs": true}`)).To(
+			Equal(&config.RelevantConfig{Name: "net1", AllowPersistentIPs: true}),
+		)
+	})
+
+	It("fails to extract the relevant configuration when the plugin interpreter fails", func() {
+		_, err := config.NewInterpreterRegistry().Config(`{"name": "net1", "type": "bridge", "ipam": "whereabouts"}`)
+		Expect(err).To(MatchError(ContainSubstring("failed to extract CNI configuration from NAD")))
+	})
+})
@@ -1,10 +1,5 @@
 package config
 
-import (
-	"encoding/json"
-	"fmt"
-)
-
 type NetworkRole string
 
 const (
@@ -15,20 +10,32 @@ const (
 
 const OVNPrimaryNetworkIPAMClaimAnnotation = "k8s.ovn.org/primary-udn-ipamclaim"
 
+// VMIResolutionPolicyAnnotation overrides, for the launcher pods of the annotated namespace, how the pods whose VMI
+// cannot be resolved are admitted
+const VMIResolutionPolicyAnnotation = "network.kubevirt.io/vmi-resolution-policy"
+
+// TopologyLayer3 is the topology of the networks whose subnets are split into per node host subnets
+const TopologyLayer3 = "layer3"
+
+// OVNKubernetesCNIType is the CNI plugin type of the OVN-Kubernetes networks
+const OVNKubernetesCNIType = "ovn-k8s-cni-overlay"
+
+// RelevantConfig is the normalized view of a network configuration, whatever the CNI plugin providing it.
+// Subnets and ExcludeSubnets are comma separated CIDR lists, holding the subnets of every IP family; the layer3
+// subnets are formatted as <cidr>/<host prefix>.
 type RelevantConfig struct {
 	Name               string      `json:"name"`
 	AllowPersistentIPs bool        `json:"allowPersistentIPs,omitempty"`
 	Role               NetworkRole `json:"role,omitempty"`
+	Topology           string      `json:"topology,omitempty"`
 	Subnets            string      `json:"subnets,omitempty"`
+	ExcludeSubnets     string      `json:"excludeSubnets,omitempty"`
+	// Gateways is the comma separated list of the gateway addresses set in an IPAM block, which the IPAM does not
+	// allocate; it is not part of the OVN-Kubernetes layout
+	Gateways string `json:"-"`
 }
 
+// NewConfig extracts the relevant configuration out of a NAD spec using the default interpreters
 func NewConfig(nadSpec string) (*RelevantConfig, error) {
-	nadConfig := &RelevantConfig{}
-	if nadSpec == "" {
-		return nadConfig, nil
-	}
-	if err := json.Unmarshal([]byte(nadSpec), nadConfig); err != nil {
-		return nil, fmt.Errorf("failed to extract CNI configuration from NAD: %w", err)
-	}
-	return nadConfig, nil
+	return defaultInterpreters.Config(nadSpec)
 }
@@ -0,0 +1,54 @@
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+var _ = Describe("NewConfig", func() {
+	DescribeTable("extracts the relevant configuration", func(nadSpec string, expectedConfig *config.RelevantConfig) {
+		Expect(config.NewConfig(nadSpec)).To(Equal(expectedConfig))
+	},
+		Entry("out of an empty spec", "", &config.RelevantConfig{}),
+		Entry("out of a single plugin configuration",
+			`{"cniVersion": "1.0.0", "name": "net1", "type": "ovn-k8s-cni-overlay", "role": "primary",
+			  "allowPersistentIPs": true, "subnets": "10.0.0.0/24"}`,
+			&config.RelevantConfig{
+				Name:               "net1",
+				Role:               config.NetworkRolePrimary,
+				AllowPersistentIPs: true,
+				Subnets:            "10.0.0.0/24",
+			},
+		),
+		Entry("out of the OVN-Kubernetes plugin of a conflist, taking the network name from the top level",
+			`{"cniVersion": "1.0.0", "name": "net1", "plugins": [
+			  {"type": "tuning", "sysctl": {"net.ipv4.conf.IFNAME.arp_notify": "1"}},
+			  {"type": "ovn-k8s-cni-overlay", "role": "primary", "allowPersistentIPs": true, "subnets": "10.0.0.0/24"},
+			  {"type": "bandwidth", "ingressRate": 1000}
+			]}`,
+			&config.RelevantConfig{
+				Name:               "net1",
+				Role:               config.NetworkRolePrimary,
+				AllowPersistentIPs: true,
+				Subnets:            "10.0.0.0/24",
+			},
+		),
+		Entry("out of the first plugin of a conflist without any plugin having a registered interpreter",
+			`{"cniVersion": "1.0.0", "name": "net1", "plugins": [
+			  {"type": "custom-cni", "allowPersistentIPs": true, "subnets": "10.0.0.0/24"},
+			  {"type": "tuning"}
+			]}`,
+			&config.RelevantConfig{Name: "net1", AllowPersistentIPs: true, Subnets: "10.0.0.0/24"},
+		),
+	)
+
+	DescribeTable("fails to extract the relevant configuration", func(nadSpec string) {
+		_, err := config.NewConfig(nadSpec)
+		Expect(err).To(MatchError(ContainSubstring("failed to extract CNI configuration from NAD")))
+	},
+		Entry("out of a malformed spec", `{"name": "net1",`),
+		Entry("out of a conflist with a malformed plugin", `{"name": "net1", "plugins": ["tuning"]}`),
+	)
+})
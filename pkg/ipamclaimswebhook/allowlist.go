@@ -0,0 +1,57 @@
+package ipamclaimswebhook
+
+import (
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	userIdentityPrefix           = "user:"
+	groupIdentityPrefix          = "group:"
+	serviceAccountIdentityPrefix = "serviceaccount:"
+)
+
+// IdentityAllowlist matches the users, groups and service accounts issuing the admission requests
+type IdentityAllowlist struct {
+	users  sets.Set[string]
+	groups sets.Set[string]
+}
+
+// ParseIdentityAllowlist parses a comma separated list of identities, each being either `user:<name>`,
+// `group:<name>` or `serviceaccount:<namespace>/<name>`
+func ParseIdentityAllowlist(rawIdentities string) (*IdentityAllowlist, error) {
+	allowlist := &IdentityAllowlist{users: sets.New[string](), groups: sets.New[string]()}
+	for _, identity := range strings.Split(rawIdentities, ",") {
+		identity = strings.TrimSpace(identity)
+		switch {
+		case identity == "":
+			continue
+		case strings.HasPrefix(identity, userIdentityPrefix):
+			allowlist.users.Insert(strings.TrimPrefix(identity, userIdentityPrefix))
+		case strings.HasPrefix(identity, groupIdentityPrefix):
+			allowlist.groups.Insert(strings.TrimPrefix(identity, groupIdentityPrefix))
+		case strings.HasPrefix(identity, serviceAccountIdentityPrefix):
+			namespace, name, found := strings.Cut(strings.TrimPrefix(identity, serviceAccountIdentityPrefix), "/")
+			if !found || namespace == "" || name == "" {
+				return nil, fmt.Errorf("invalid service account identity %q, expected %s<namespace>/<name>",
+					identity, serviceAccountIdentityPrefix)
+			}
+			allowlist.users.Insert(fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name))
+		default:
+			return nil, fmt.Errorf("invalid identity %q, expected it to start with %q, %q or %q",
+				identity, userIdentityPrefix, groupIdentityPrefix, serviceAccountIdentityPrefix)
+		}
+	}
+	return allowlist, nil
+}
+
+// allows reports whether the user, or one of its groups, is in the allowlist; a nil allowlist allows no one
+func (l *IdentityAllowlist) allows(userInfo authenticationv1.UserInfo) bool {
+	if l == nil {
+		return false
+	}
+	return l.users.Has(userInfo.Username) || l.groups.HasAny(userInfo.Groups...)
+}
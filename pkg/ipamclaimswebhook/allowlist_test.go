@@ -0,0 +1,41 @@
+package ipamclaimswebhook
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+var _ = Describe("identity allowlist", func() {
+	DescribeTable("allows the listed users, groups and service accounts",
+		func(rawIdentities string, userInfo authenticationv1.UserInfo, expectedAllowed bool) {
+			allowlist, err := ParseIdentityAllowlist(rawIdentities)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(allowlist.allows(userInfo)).To(Equal(expectedAllowed))
+		},
+		Entry("listed user", "user:alice", authenticationv1.UserInfo{Username: "alice"}, true),
+		Entry("unlisted user", "user:alice", authenticationv1.UserInfo{Username: "bob"}, false),
+		Entry("member of a listed group", "group:admins, user:alice",
+			authenticationv1.UserInfo{Username: "bob", Groups: []string{"devs", "admins"}}, true),
+		Entry("listed service account", "serviceaccount:kubevirt/migrator",
+			authenticationv1.UserInfo{Username: "system:serviceaccount:kubevirt:migrator"}, true),
+		Entry("service account of another namespace", "serviceaccount:kubevirt/migrator",
+			authenticationv1.UserInfo{Username: "system:serviceaccount:default:migrator"}, false),
+		Entry("empty allowlist", "", authenticationv1.UserInfo{Username: "alice"}, false),
+	)
+
+	It("allows no one when not configured", func() {
+		var allowlist *IdentityAllowlist
+		Expect(allowlist.allows(authenticationv1.UserInfo{Username: "alice"})).To(BeFalse())
+	})
+
+	DescribeTable("rejects invalid identities", func(rawIdentities string) {
+		_, err := ParseIdentityAllowlist(rawIdentities)
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("identity without a kind", "alice"),
+		Entry("unknown identity kind", "role:admin"),
+		Entry("service account without a namespace", "serviceaccount:migrator"),
+	)
+})
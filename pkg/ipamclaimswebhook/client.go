@@ -2,47 +2,70 @@ package ipamclaimswebhook
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	k8swait "k8s.io/apimachinery/pkg/util/wait"
-	k8sretry "k8s.io/client-go/util/retry"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
 	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/metrics"
 )
 
+// defaultClientTimeout is the API server read budget of the requests carrying no deadline
 const defaultClientTimeout = 2 * time.Second
-const defaultClientInterval = 500 * time.Millisecond
 
-// getAndRetryOnNotFound an object according to 'key' using controller runtime client.
-// When the object not found it retries until found or timeout.
-// This function come in handy for fetching VMIs, when VMI and associated pod
-// exist but the VMI is missing from the controller-runtime client informer cache.
-func getAndRetryOnNotFound(
+// responseMargin is the part of the admission request deadline kept to answer the API server once the reads are over
+const responseMargin = time.Second
+
+type requestDeadlineKey struct{}
+
+// WithRequestDeadline records the deadline of the admission request in the context; the API server sends the
+// webhook timeout as the `timeout` query parameter of the request. It is meant to be used as the admission webhook
+// context function.
+func WithRequestDeadline(ctx context.Context, request *http.Request) context.Context {
+	timeout, err := time.ParseDuration(request.URL.Query().Get("timeout"))
+	if err != nil || timeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestDeadlineKey{}, time.Now().Add(timeout))
+}
+
+// readDeadline returns the deadline of the API server reads: the admission request deadline minus the margin
+// required to answer it, or the default budget when the request carries no deadline
+func readDeadline(ctx context.Context) time.Time {
+	deadline, hasDeadline := ctx.Deadline()
+	if requestDeadline, ok := ctx.Value(requestDeadlineKey{}).(time.Time); ok &&
+		(!hasDeadline || requestDeadline.Before(deadline)) {
+		deadline, hasDeadline = requestDeadline, true
+	}
+	if !hasDeadline {
+		return time.Now().Add(defaultClientTimeout)
+	}
+	return deadline.Add(-responseMargin)
+}
+
+// getVMI reads the VMI from the informer cache, falling back to a single read from the API server when the VMI is
+// missing from the cache - i.e. when the launcher pod is admitted before the informer caught up with the VMI
+// creation. The API server read is bounded by the admission request deadline.
+func getVMI(
 	ctx context.Context,
-	cli crclient.Client,
+	cachedReader crclient.Reader,
+	apiReader crclient.Reader,
 	key crclient.ObjectKey,
-	outObj crclient.Object,
+	vmi *virtv1.VirtualMachineInstance,
 ) error {
-	log := crlog.FromContext(ctx)
-	gvk := outObj.GetObjectKind().GroupVersionKind().String()
-	b := k8swait.Backoff{
-		Cap:      defaultClientTimeout,
-		Duration: defaultClientInterval,
-		Steps:    4,
-		Factor:   1.0,
-		Jitter:   0.1,
+	err := cachedReader.Get(ctx, key, vmi)
+	if !k8serrors.IsNotFound(err) {
+		return err
 	}
-	return k8sretry.OnError(b,
-		func(err error) bool {
-			if !k8serrors.IsNotFound(err) {
-				return false
-			}
-			log.Info("WARNING: Object not found:", "key", key, "kind", gvk, "err", err)
-			return true
-		},
-		func() error {
-			return cli.Get(ctx, key, outObj)
-		},
-	)
+
+	crlog.FromContext(ctx).Info("VMI not found in the cache, reading it from the API server", "vmi", key)
+	metrics.ReportVMICacheMiss(key.Namespace)
+
+	readCtx, cancel := context.WithDeadline(ctx, readDeadline(ctx))
+	defer cancel()
+	return apiReader.Get(readCtx, key, vmi)
 }
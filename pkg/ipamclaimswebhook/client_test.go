@@ -0,0 +1,46 @@
+package ipamclaimswebhook
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VMI API server read budget", func() {
+	It("is the default budget when the request carries no deadline", func() {
+		Expect(readDeadline(context.Background())).To(
+			BeTemporally("~", time.Now().Add(defaultClientTimeout), 100*time.Millisecond))
+	})
+
+	It("follows the admission request timeout, keeping a margin to answer", func() {
+		request, err := http.NewRequest(http.MethodPost, "/mutate-v1-pod?timeout=10s", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx := WithRequestDeadline(context.Background(), request)
+
+		Expect(readDeadline(ctx)).To(
+			BeTemporally("~", time.Now().Add(10*time.Second-responseMargin), 100*time.Millisecond))
+	})
+
+	It("follows the context deadline when it expires before the admission request timeout", func() {
+		request, err := http.NewRequest(http.MethodPost, "/mutate-v1-pod?timeout=10s", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ctx = WithRequestDeadline(ctx, request)
+
+		Expect(readDeadline(ctx)).To(
+			BeTemporally("~", time.Now().Add(5*time.Second-responseMargin), 100*time.Millisecond))
+	})
+
+	It("ignores an invalid admission request timeout", func() {
+		request, err := http.NewRequest(http.MethodPost, "/mutate-v1-pod?timeout=soon", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(WithRequestDeadline(context.Background(), request)).To(Equal(context.Background()))
+	})
+})
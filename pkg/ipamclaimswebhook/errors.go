@@ -0,0 +1,159 @@
+package ipamclaimswebhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevirt/ipam-extensions/pkg/ips"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
+)
+
+// Reason is a stable, machine readable identifier of an admission failure. It is reported as the type of the
+// admission response status causes, hence must not change once released.
+type Reason string
+
+const (
+	// ReasonMalformedRequest reports an admission request object which cannot be decoded or parsed
+	ReasonMalformedRequest Reason = "MalformedRequest"
+	// ReasonNotLauncherPod reports a pod carrying the VM annotation which is not the VMI virt-launcher pod
+	ReasonNotLauncherPod Reason = "NotLauncherPod"
+	// ReasonIPAMClaimNotOwned reports a pod referencing an IPAMClaim its VM does not own
+	ReasonIPAMClaimNotOwned Reason = "IPAMClaimNotOwned"
+	// ReasonAmbiguousPrimaryNetwork reports a VM attached to a namespace holding several primary networks
+	ReasonAmbiguousPrimaryNetwork Reason = "AmbiguousPrimaryNetwork"
+	// ReasonInvalidUserDefinedNetwork reports a VM attached to a namespace whose primary network may be a user
+	// defined network whose spec cannot be read
+	ReasonInvalidUserDefinedNetwork Reason = "InvalidUserDefinedNetwork"
+	// ReasonInvalidIPRequests reports IP or default route requests which cannot be honored on the network
+	ReasonInvalidIPRequests Reason = "InvalidIPRequests"
+	// ReasonUnsupportedIPRequests reports IP requests on a network which does not support them
+	ReasonUnsupportedIPRequests Reason = "UnsupportedIPRequests"
+	// ReasonIPAlreadyHeld reports an IP request for an IP held by another VM on the same network
+	ReasonIPAlreadyHeld Reason = "IPAlreadyHeld"
+	// ReasonConflictingNetworkRequests reports a network selection element conflicting with the VM requests
+	ReasonConflictingNetworkRequests Reason = "ConflictingNetworkRequests"
+	// ReasonDefaultNetworkNotAllowed reports a pod setting the multus default network annotation by itself
+	ReasonDefaultNetworkNotAllowed Reason = "DefaultNetworkAnnotationNotAllowed"
+	// ReasonImmutableNetworkRequests reports an update changing the network requests of a launcher pod
+	ReasonImmutableNetworkRequests Reason = "ImmutableNetworkRequests"
+	// ReasonVMINotFound reports a launcher pod whose VMI cannot be found, e.g. not yet in the informer cache
+	ReasonVMINotFound Reason = "VMINotFound"
+	// ReasonInvalidNADConfig reports a NAD whose configuration cannot be parsed, which the VM (e.g. through the
+	// namespace primary network) may depend on
+	ReasonInvalidNADConfig Reason = "InvalidNADConfig"
+	// ReasonInternalError reports any other failure to handle the admission request
+	ReasonInternalError Reason = "InternalError"
+)
+
+// ValidationError represents a validation failure (should result in admission.Denied)
+type ValidationError struct {
+	Reason Reason
+	// Field is the path of the offending field, when known
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+// RequestError represents a malformed admission request (should result in admission.Errored with a 400 code)
+type RequestError struct {
+	Reason Reason
+	Err    error
+}
+
+func (e RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e RequestError) Unwrap() error {
+	return e.Err
+}
+
+// ServerError represents a failure to handle a well formed admission request (should result in admission.Errored
+// with a 500 code)
+type ServerError struct {
+	Reason Reason
+	Err    error
+}
+
+func (e ServerError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ServerError) Unwrap() error {
+	return e.Err
+}
+
+// ipRequestsError classifies the failure to compute the VM interface IP or gateway requests
+func ipRequestsError(err error) error {
+	return ValidationError{Reason: ipRequestsReason(err), Message: err.Error()}
+}
+
+func ipRequestsReason(err error) Reason {
+	if errors.Is(err, ips.ErrUnsupportedIPRequests) {
+		return ReasonUnsupportedIPRequests
+	}
+	return ReasonInvalidIPRequests
+}
+
+// unresolvedPrimaryNetworkError reports a VM attached to the pod network of a namespace whose primary network may be
+// one of the NADs whose configuration cannot be parsed
+func unresolvedPrimaryNetworkError(namespace string, invalidNADNames []string) error {
+	return ValidationError{
+		Reason: ReasonInvalidNADConfig,
+		Message: fmt.Sprintf("the primary network of namespace %q cannot be resolved since the configuration of "+
+			"the NADs %v cannot be parsed", namespace, invalidNADNames),
+	}
+}
+
+func invalidNADConfigError(nadName string, err error) error {
+	return ServerError{
+		Reason: ReasonInvalidNADConfig,
+		Err:    fmt.Errorf("failed to parse the NAD %s configuration: %w", nadName, err),
+	}
+}
+
+// errorResponse returns the admission response reporting the error; the error type determines whether the request
+// is denied, or errored with a client or server error code. The failure reason is reported in the status details.
+func errorResponse(err error) admission.Response {
+	var (
+		validationErr ValidationError
+		requestErr    RequestError
+		serverErr     ServerError
+	)
+	switch {
+	case errors.As(err, &validationErr):
+		return withCause(admission.Denied(err.Error()), validationErr.Reason, validationErr.Field, err.Error())
+	case errors.Is(err, ips.ErrUnsupportedIPRequests):
+		return withCause(admission.Denied(err.Error()), ReasonUnsupportedIPRequests, "", err.Error())
+	case errors.Is(err, udn.ErrAmbiguousPrimaryNetwork):
+		return withCause(admission.Denied(err.Error()), ReasonAmbiguousPrimaryNetwork, "", err.Error())
+	case errors.Is(err, udn.ErrInvalidUserDefinedNetwork):
+		return withCause(
+			admission.Errored(http.StatusInternalServerError, err), ReasonInvalidUserDefinedNetwork, "", err.Error())
+	case errors.As(err, &requestErr):
+		return withCause(admission.Errored(http.StatusBadRequest, err), requestErr.Reason, "", err.Error())
+	case errors.As(err, &serverErr):
+		return withCause(admission.Errored(http.StatusInternalServerError, err), serverErr.Reason, "", err.Error())
+	default:
+		return withCause(admission.Errored(http.StatusInternalServerError, err), ReasonInternalError, "", err.Error())
+	}
+}
+
+func withCause(response admission.Response, reason Reason, field string, message string) admission.Response {
+	response.Result.Details = &metav1.StatusDetails{
+		Causes: []metav1.StatusCause{{
+			Type:    metav1.CauseType(reason),
+			Message: message,
+			Field:   field,
+		}},
+	}
+	return response
+}
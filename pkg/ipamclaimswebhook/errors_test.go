@@ -0,0 +1,63 @@
+package ipamclaimswebhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/ips"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
+)
+
+var _ = Describe("admission error responses", func() {
+	DescribeTable("report the failure reason with a code following from the error type",
+		func(err error, expectedCode int32, expectedReason Reason) {
+			response := errorResponse(err)
+
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Code).To(Equal(expectedCode))
+			Expect(response.Result.Message).To(Equal(err.Error()))
+			Expect(response.Result.Details.Causes).To(ConsistOf(metav1.StatusCause{
+				Type:    metav1.CauseType(expectedReason),
+				Message: err.Error(),
+			}))
+		},
+		Entry("validation error is denied",
+			ValidationError{Reason: ReasonConflictingNetworkRequests, Message: "conflict"},
+			int32(http.StatusForbidden), ReasonConflictingNetworkRequests,
+		),
+		Entry("wrapped validation error is denied",
+			fmt.Errorf("wrapped: %w", ValidationError{Reason: ReasonNotLauncherPod, Message: "not a launcher"}),
+			int32(http.StatusForbidden), ReasonNotLauncherPod,
+		),
+		Entry("unsupported IP requests are denied",
+			fmt.Errorf("%w on the layer3 network", ips.ErrUnsupportedIPRequests),
+			int32(http.StatusForbidden), ReasonUnsupportedIPRequests,
+		),
+		Entry("ambiguous primary network is denied",
+			fmt.Errorf("%w: several NADs", udn.ErrAmbiguousPrimaryNetwork),
+			int32(http.StatusForbidden), ReasonAmbiguousPrimaryNetwork,
+		),
+		Entry("unreadable primary user defined network is a server error",
+			fmt.Errorf("%w: UserDefinedNetwork [ns1/udn1]", udn.ErrInvalidUserDefinedNetwork),
+			int32(http.StatusInternalServerError), ReasonInvalidUserDefinedNetwork,
+		),
+		Entry("malformed request is a client error",
+			RequestError{Reason: ReasonMalformedRequest, Err: errors.New("cannot decode")},
+			int32(http.StatusBadRequest), ReasonMalformedRequest,
+		),
+		Entry("server error is a server error",
+			ServerError{Reason: ReasonVMINotFound, Err: errors.New("VMI not found")},
+			int32(http.StatusInternalServerError), ReasonVMINotFound,
+		),
+		Entry("unclassified error is an internal server error",
+			errors.New("boom"),
+			int32(http.StatusInternalServerError), ReasonInternalError,
+		),
+	)
+})
@@ -0,0 +1,65 @@
+package ipamclaimswebhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/claims"
+)
+
+// ipamClaimRequests collects the IPAMClaims the mutated pod references, mapping the VMI logical network name to the
+// name of the network persisting its IPs
+type ipamClaimRequests map[string]string
+
+// ensureIPAMClaims creates the IPAMClaims the pod references, as the VMI controller does: the pod may be admitted
+// before the VMI is reconciled, while the CNI plugin requires the referenced claims to exist. The claims created by
+// the controller in the meantime are kept as they are, and none is persisted on dry-run requests.
+func (a *IPAMClaimsValet) ensureIPAMClaims(
+	ctx context.Context,
+	request admission.Request,
+	vmi *virtv1.VirtualMachineInstance,
+	ipamClaims ipamClaimRequests,
+) error {
+	var createOpts []client.CreateOption
+	if ptr.Deref(request.DryRun, false) {
+		createOpts = append(createOpts, client.DryRunAll)
+	}
+
+	ownerInfo := claims.OwnerReferenceFor(vmi, vmiOwningVM(vmi))
+	for _, logicalNetworkName := range slices.Sorted(maps.Keys(ipamClaims)) {
+		ipamClaim := claims.NewIPAMClaim(vmi, ownerInfo, logicalNetworkName, ipamClaims[logicalNetworkName])
+		if err := claims.Ensure(ctx, a.Client, a.apiReader, ipamClaim, createOpts...); err != nil {
+			if errors.Is(err, claims.ErrLeakedIPAMClaim) {
+				return ValidationError{
+					Reason: ReasonIPAMClaimNotOwned,
+					Message: fmt.Sprintf("IPAMClaim %q already exists and is not owned by %s %q",
+						ipamClaim.Name, ownerInfo.Kind, ownerInfo.Name),
+				}
+			}
+			return fmt.Errorf("failed to create the IPAMClaim %q: %w", ipamClaim.Name, err)
+		}
+	}
+	return nil
+}
+
+// vmiOwningVM returns the VM controlling the VMI, as far as the IPAMClaims owner reference is concerned - i.e. its
+// name and UID, read from the VMI owner reference rather than from a cache which may lag behind; nil when the VMI
+// has no VM
+func vmiOwningVM(vmi *virtv1.VirtualMachineInstance) *virtv1.VirtualMachine {
+	controller := metav1.GetControllerOf(vmi)
+	if controller == nil || controller.Kind != virtv1.VirtualMachineGroupVersionKind.Kind {
+		return nil
+	}
+	return &virtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: controller.Name, UID: controller.UID}}
+}
@@ -0,0 +1,396 @@
+package ipamclaimswebhook
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/ips"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
+)
+
+// +kubebuilder:webhook:path=/validate-kubevirt-io-v1-ip-requests,mutating=false,failurePolicy=fail,groups=kubevirt.io,resources=virtualmachines;virtualmachineinstances,verbs=create;update,versions=v1,name=ip-requests.kubevirt.io,admissionReviewVersions=v1,sideEffects=None
+//nolint:lll
+
+var ipRequestsAnnotationField = fmt.Sprintf("metadata.annotations[%s]", config.IPRequestsAnnotation)
+
+// IPRequestsValidator validates the IP requests of VirtualMachines and VirtualMachineInstances
+type IPRequestsValidator struct {
+	client.Client
+	decoder         admission.Decoder
+	nadConfigs      *nads.ConfigCache
+	primaryNetworks *udn.PrimaryNetworkFinder
+}
+
+func NewIPRequestsValidator(
+	manager manager.Manager,
+	nadConfigs *nads.ConfigCache,
+	primaryNetworks *udn.PrimaryNetworkFinder,
+) *IPRequestsValidator {
+	return &IPRequestsValidator{
+		decoder:         admission.NewDecoder(manager.GetScheme()),
+		Client:          manager.GetClient(),
+		nadConfigs:      nadConfigs,
+		primaryNetworks: primaryNetworks,
+	}
+}
+
+// ipRequestsSource holds the VMI data relevant for the IP requests validation
+type ipRequestsSource struct {
+	vmName      string
+	annotations map[string]string
+	spec        *virtv1.VirtualMachineInstanceSpec
+}
+
+func (v *IPRequestsValidator) Handle(ctx context.Context, request admission.Request) admission.Response {
+	log := logf.FromContext(ctx)
+
+	source, err := v.decodeIPRequestsSource(request.Kind.Kind, request.Object)
+	if err != nil {
+		return errorResponse(RequestError{Reason: ReasonMalformedRequest, Err: err})
+	}
+
+	if _, hasIPRequests := source.annotations[config.IPRequestsAnnotation]; !hasIPRequests {
+		return admission.Allowed("no IP requests")
+	}
+
+	if request.Operation == admissionv1.Update {
+		oldSource, err := v.decodeIPRequestsSource(request.Kind.Kind, request.OldObject)
+		if err != nil {
+			return errorResponse(RequestError{Reason: ReasonMalformedRequest, Err: err})
+		}
+		if !hasIPRequestsChanged(oldSource, source) {
+			return admission.Allowed("IP requests not changed")
+		}
+	}
+
+	log.V(1).Info("validating IP requests", "kind", request.Kind.Kind, "name", request.Name)
+
+	if err := v.validateIPRequests(ctx, request.Namespace, source); err != nil {
+		return errorResponse(err)
+	}
+
+	return admission.Allowed("valid IP requests")
+}
+
+func (v *IPRequestsValidator) decodeIPRequestsSource(
+	kind string,
+	rawObj runtime.RawExtension,
+) (*ipRequestsSource, error) {
+	switch kind {
+	case "VirtualMachine":
+		vm := &virtv1.VirtualMachine{}
+		if err := v.decoder.DecodeRaw(rawObj, vm); err != nil {
+			return nil, err
+		}
+		if vm.Spec.Template == nil {
+			return &ipRequestsSource{vmName: vm.Name}, nil
+		}
+		return &ipRequestsSource{
+			vmName:      vm.Name,
+			annotations: vm.Spec.Template.ObjectMeta.Annotations,
+			spec:        &vm.Spec.Template.Spec,
+		}, nil
+	case "VirtualMachineInstance":
+		vmi := &virtv1.VirtualMachineInstance{}
+		if err := v.decoder.DecodeRaw(rawObj, vmi); err != nil {
+			return nil, err
+		}
+		return &ipRequestsSource{
+			vmName:      vmi.Name,
+			annotations: vmi.Annotations,
+			spec:        &vmi.Spec,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected kind %q", kind)
+	}
+}
+
+func hasIPRequestsChanged(oldSource, newSource *ipRequestsSource) bool {
+	if oldSource.annotations[config.IPRequestsAnnotation] != newSource.annotations[config.IPRequestsAnnotation] {
+		return true
+	}
+	if oldSource.spec == nil || newSource.spec == nil {
+		return oldSource.spec != newSource.spec
+	}
+	return !reflect.DeepEqual(oldSource.spec.Networks, newSource.spec.Networks) ||
+		!reflect.DeepEqual(oldSource.spec.Domain.Devices.Interfaces, newSource.spec.Domain.Devices.Interfaces)
+}
+
+func (v *IPRequestsValidator) validateIPRequests(
+	ctx context.Context,
+	namespace string,
+	source *ipRequestsSource,
+) error {
+	interfaceRequests, err := ips.ParseInterfaceRequests(source.annotations)
+	if err != nil {
+		return ValidationError{
+			Reason:  ReasonInvalidIPRequests,
+			Field:   ipRequestsAnnotationField,
+			Message: fmt.Sprintf("failed to parse the %q annotation: %v", config.IPRequestsAnnotation, err),
+		}
+	}
+
+	ifaceNames := make([]string, 0, len(interfaceRequests))
+	var defaultRouteIfaceNames []string
+	for ifaceName, requests := range interfaceRequests {
+		ifaceNames = append(ifaceNames, ifaceName)
+		if requests.DefaultRoute {
+			defaultRouteIfaceNames = append(defaultRouteIfaceNames, ifaceName)
+		}
+	}
+	sort.Strings(ifaceNames)
+	if len(defaultRouteIfaceNames) > 1 {
+		sort.Strings(defaultRouteIfaceNames)
+		return ValidationError{
+			Reason: ReasonInvalidIPRequests,
+			Field:  ipRequestsAnnotationField,
+			Message: fmt.Sprintf("%q annotation requests the default route on several interfaces %v",
+				config.IPRequestsAnnotation, defaultRouteIfaceNames),
+		}
+	}
+
+	for _, ifaceName := range ifaceNames {
+		if source.spec == nil || !hasInterface(source.spec, ifaceName) {
+			return ValidationError{
+				Reason: ReasonInvalidIPRequests,
+				Field:  ipRequestsAnnotationField,
+				Message: fmt.Sprintf("%q annotation requests IPs for interface %q which is not defined "+
+					"at spec.domain.devices.interfaces", config.IPRequestsAnnotation, ifaceName),
+			}
+		}
+
+		netConfig, err := v.interfaceNetworkConfig(ctx, namespace, source.spec, ifaceName)
+		if err != nil {
+			return err
+		}
+		if netConfig == nil {
+			continue
+		}
+
+		requests := interfaceRequests[ifaceName]
+		if err := ips.ValidateIPRequests(requests.IPs, netConfig); err != nil {
+			return ValidationError{
+				Reason: ipRequestsReason(err),
+				Field:  ipRequestsAnnotationField,
+				Message: fmt.Sprintf("invalid IP requests for interface %q on network %q: %v",
+					ifaceName, netConfig.Name, err),
+			}
+		}
+		if err := ips.ValidateGatewayRequests(requests, netConfig); err != nil {
+			return ValidationError{
+				Reason: ipRequestsReason(err),
+				Field:  ipRequestsAnnotationField,
+				Message: fmt.Sprintf("invalid default route request for interface %q on network %q: %v",
+					ifaceName, netConfig.Name, err),
+			}
+		}
+
+		if err := v.ensureIPsNotHeld(ctx, namespace, source.vmName, netConfig.Name, ifaceName,
+			requests.IPs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureIPsNotHeld rejects the IPs already claimed on the network, or requested on the same network by another VM,
+// whatever its namespace
+func (v *IPRequestsValidator) ensureIPsNotHeld(
+	ctx context.Context,
+	namespace string,
+	vmName string,
+	networkName string,
+	ifaceName string,
+	ipRequests []string,
+) error {
+	vmKey := types.NamespacedName{Namespace: namespace, Name: vmName}
+	for _, ipRequest := range ipRequests {
+		ip, err := ips.CanonicalIP(ipRequest)
+		if err != nil {
+			return err
+		}
+		holderVMKey, err := v.ipHolder(ctx, vmKey, networkName, ip)
+		if err != nil {
+			return err
+		}
+		if holderVMKey != nil {
+			holderVMName := holderVMKey.Name
+			if holderVMKey.Namespace != namespace {
+				holderVMName = holderVMKey.String()
+			}
+			return ValidationError{
+				Reason: ReasonIPAlreadyHeld,
+				Field:  ipRequestsAnnotationField,
+				Message: fmt.Sprintf("IP %s requested for interface %q is already held by VM %q on network %q",
+					ip, ifaceName, holderVMName, networkName),
+			}
+		}
+	}
+	return nil
+}
+
+// ipHolder returns the key of another VM holding the IP on the network, either through an IPAMClaim or through
+// its IP requests; nil when none does. The network name identifies the network cluster wide - e.g. the NADs of
+// several namespaces may refer to the same OVN-Kubernetes network - hence the holders are looked up in every
+// namespace.
+func (v *IPRequestsValidator) ipHolder(
+	ctx context.Context,
+	vmKey types.NamespacedName,
+	networkName string,
+	ip string,
+) (*types.NamespacedName, error) {
+	ipamClaims := &ipamclaimsapi.IPAMClaimList{}
+	if err := v.List(ctx, ipamClaims,
+		client.MatchingFields{ips.ClaimedIPsIndex: ips.IndexKey(networkName, ip)}); err != nil {
+		return nil, fmt.Errorf("failed listing the IPAMClaims holding IP %s: %w", ip, err)
+	}
+	for _, claim := range ipamClaims.Items {
+		holderVMName := claimHolderVMName(&claim)
+		if holderVMName == "" {
+			continue
+		}
+		if holderVMKey := (types.NamespacedName{Namespace: claim.Namespace, Name: holderVMName}); holderVMKey != vmKey {
+			return &holderVMKey, nil
+		}
+	}
+
+	vmis := &virtv1.VirtualMachineInstanceList{}
+	if err := v.List(ctx, vmis, client.MatchingFields{ips.RequestedIPsIndex: ip}); err != nil {
+		return nil, fmt.Errorf("failed listing the VMIs requesting IP %s: %w", ip, err)
+	}
+	var networkNADKeys []types.NamespacedName
+	for i := range vmis.Items {
+		vmi := &vmis.Items[i]
+		holderVMKey := types.NamespacedName{Namespace: vmi.Namespace, Name: vmi.Name}
+		if holderVMKey == vmKey {
+			continue
+		}
+		if networkNADKeys == nil {
+			var err error
+			if networkNADKeys, err = nads.FindNetworkNADs(ctx, v.Client, networkName); err != nil {
+				return nil, err
+			}
+		}
+		if v.requestsIPOnNetwork(ctx, vmi, networkName, networkNADKeys, ip) {
+			return &holderVMKey, nil
+		}
+	}
+	return nil, nil
+}
+
+// requestsIPOnNetwork reports whether one of the VMI interfaces attached to the network requests the IP; the
+// network of the interfaces is resolved on lookup, since the NADs (or user defined networks) may have changed
+// since the VMI was indexed. The interfaces whose network cannot be resolved are ignored.
+func (v *IPRequestsValidator) requestsIPOnNetwork(
+	ctx context.Context,
+	vmi *virtv1.VirtualMachineInstance,
+	networkName string,
+	networkNADKeys []types.NamespacedName,
+	ip string,
+) bool {
+	ipRequests, err := ips.ParseIPRequests(vmi.Annotations)
+	if err != nil {
+		return false
+	}
+	for _, network := range vmi.Spec.Networks {
+		if !slices.ContainsFunc(ipRequests[network.Name], func(ipRequest string) bool {
+			requestedIP, err := ips.CanonicalIP(ipRequest)
+			return err == nil && requestedIP == ip
+		}) {
+			continue
+		}
+		if network.Multus != nil &&
+			slices.Contains(networkNADKeys, nads.MultusNetworkNADKey(vmi.Namespace, network.Multus.NetworkName)) {
+			return true
+		}
+		if network.Pod != nil {
+			netConfig, err := primaryNetworkConfig(v.Client, ctx, v.primaryNetworks, vmi.Namespace)
+			if err != nil {
+				logf.FromContext(ctx).Info("ignoring the IP requests of a VMI whose primary network cannot be resolved",
+					"VMI", types.NamespacedName{Namespace: vmi.Namespace, Name: vmi.Name}.String(), "reason", err.Error())
+				continue
+			}
+			if netConfig != nil && netConfig.Name == networkName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimHolderVMName returns the name of the VM owning the IPAMClaim, as labeled by the VMI controller
+func claimHolderVMName(claim *ipamclaimsapi.IPAMClaim) string {
+	return claim.Labels[virtv1.VirtualMachineLabel]
+}
+
+func hasInterface(spec *virtv1.VirtualMachineInstanceSpec, ifaceName string) bool {
+	for _, iface := range spec.Domain.Devices.Interfaces {
+		if iface.Name == ifaceName {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the configuration of the network the interface is attached to; nil when it cannot be found
+func (v *IPRequestsValidator) interfaceNetworkConfig(
+	ctx context.Context,
+	namespace string,
+	spec *virtv1.VirtualMachineInstanceSpec,
+	ifaceName string,
+) (*config.RelevantConfig, error) {
+	for _, network := range spec.Networks {
+		if network.Name != ifaceName {
+			continue
+		}
+		if network.Pod != nil {
+			return primaryNetworkConfig(v.Client, ctx, v.primaryNetworks, namespace)
+		}
+		if network.Multus != nil {
+			return nadNetworkConfig(ctx, v.Client, v.nadConfigs, nads.MultusNetworkNADKey(namespace, network.Multus.NetworkName))
+		}
+	}
+	return nil, nil
+}
+
+func nadNetworkConfig(
+	ctx context.Context,
+	cli client.Reader,
+	nadConfigs *nads.ConfigCache,
+	nadKey types.NamespacedName,
+) (*config.RelevantConfig, error) {
+	nad := v1.NetworkAttachmentDefinition{}
+	if err := cli.Get(ctx, nadKey, &nad); err != nil {
+		if k8serrors.IsNotFound(err) {
+			logf.FromContext(ctx).Info("NAD not found, cannot validate the IP requests", "NAD", nadKey.String())
+			return nil, nil
+		}
+		return nil, err
+	}
+	pluginConfig, err := nadConfigs.Config(&nad)
+	if err != nil {
+		return nil, invalidNADConfigError(nadKey.String(), err)
+	}
+	return pluginConfig, nil
+}
@@ -0,0 +1,550 @@
+package ipamclaimswebhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/ips"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
+)
+
+type validatorTestConfig struct {
+	inputNADs                 []*nadv1.NetworkAttachmentDefinition
+	inputObjects              []client.Object
+	request                   admission.Request
+	expectedAdmissionResponse admissionv1.AdmissionResponse
+}
+
+var _ = Describe("KubeVirt IP requests validator", func() {
+	const nadName = "ns1/supadupanet"
+
+	BeforeEach(func() {
+		log.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+		Expect(virtv1.AddToScheme(scheme.Scheme)).To(Succeed())
+		Expect(nadv1.AddToScheme(scheme.Scheme)).To(Succeed())
+		Expect(ipamclaimsapi.AddToScheme(scheme.Scheme)).To(Succeed())
+	})
+
+	DescribeTable("admits / rejects VM and VMI requests as expected", func(config validatorTestConfig) {
+		var initialObjects []client.Object
+		for _, nad := range config.inputNADs {
+			initialObjects = append(initialObjects, nad)
+		}
+		initialObjects = append(initialObjects, config.inputObjects...)
+
+		nadConfigs := nads.NewConfigCache()
+		primaryNetworks := udn.NewPrimaryNetworkFinder(nadConfigs)
+		ctrlOptions := controllerruntime.Options{
+			Scheme: scheme.Scheme,
+			NewClient: func(_ *rest.Config, _ client.Options) (client.Client, error) {
+				return withIPIndexes(withNADIndexes(fake.NewClientBuilder(), nadConfigs)).
+					WithScheme(scheme.Scheme).
+					WithObjects(initialObjects...).
+					Build(), nil
+			},
+		}
+
+		mgr, err := controllerruntime.NewManager(&rest.Config{}, ctrlOptions)
+		Expect(err).NotTo(HaveOccurred())
+
+		validator := NewIPRequestsValidator(mgr, nadConfigs, primaryNetworks)
+
+		result := validator.Handle(context.Background(), config.request)
+
+		Expect(result.AdmissionResponse).To(Equal(config.expectedAdmissionResponse))
+	},
+		Entry("VMI without IP requests is accepted", validatorTestConfig{
+			request:                   vmiAdmissionRequest(dummyVMI(nadName), admissionv1.Create),
+			expectedAdmissionResponse: allowedResponse("no IP requests"),
+		}),
+		Entry("VMI requesting IPs within the primary user defined network subnets is accepted", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyPrimaryNetworkNAD(nadName)},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: allowedResponse("valid IP requests"),
+		}),
+		Entry("VMI requesting IPs within the secondary network subnets is accepted", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: allowedResponse("valid IP requests"),
+		}),
+		Entry("VMI requesting IPs on a secondary network whose NAD does not exist is accepted", validatorTestConfig{
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: allowedResponse("valid IP requests"),
+		}),
+		Entry("VMI with a malformed IP requests annotation is rejected", validatorTestConfig{
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withAnnotation(config.IPRequestsAnnotation, "{not json}")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`failed to parse the "network.kubevirt.io/addresses" `+
+					`annotation: invalid character 'n' looking for beginning of object key string`),
+		}),
+		Entry("VMI requesting IPs for an unknown interface is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyPrimaryNetworkNAD(nadName)},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, WithIPRequests("ghostnet", "192.168.1.10")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`"network.kubevirt.io/addresses" annotation requests IPs `+
+					`for interface "ghostnet" which is not defined at spec.domain.devices.interfaces`),
+		}),
+		Entry("VMI requesting an IP outside the network subnets is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyPrimaryNetworkNAD(nadName)},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, WithIPRequests("podnet", "10.0.0.10")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "podnet" on network `+
+					`"primarynet": IP request 10.0.0.10 is not within the network subnets [192.168.0.0/16]`),
+		}),
+		Entry("VMI requesting an IP of a family the network does not serve is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "fd12:1234::200")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "randomnet" on network `+
+					`"goodnet": no IPv6 subnet configured for IPv6 IP request: fd12:1234::200`),
+		}),
+		Entry("VMI requesting an IP within the network excluded subnets is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "subnets": "10.10.0.0/24", "excludeSubnets": "10.10.0.0/28"}`),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "randomnet" on network `+
+					`"goodnet": IP request 10.10.0.5 is within the excluded subnet 10.10.0.0/28 of the network`),
+		}),
+		Entry("VMI requesting the gateway address of the network subnet is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "type": "bridge", "ipam": {"type": "whereabouts", `+
+					`"range": "10.10.0.0/24", "gateway": "10.10.0.254", "allowPersistentIPs": true}}`),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.254")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "randomnet" on network `+
+					`"goodnet": IP request 10.10.0.254 is the reserved gateway address of the network subnet `+
+					`10.10.0.0/24`),
+		}),
+		Entry("VMI requesting the first host address of a secondary network without gateway is accepted",
+			validatorTestConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+				request: vmiAdmissionRequest(
+					dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.1")),
+					admissionv1.Create,
+				),
+				expectedAdmissionResponse: allowedResponse("valid IP requests"),
+			},
+		),
+		Entry("VMI requesting IPs on a layer3 network is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "topology": "layer3", "subnets": "10.10.0.0/16/24"}`),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonUnsupportedIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "randomnet" on network `+
+					`"goodnet": IP requests are not supported on the layer3 network "goodnet": its subnets are split into `+
+					`per node host subnets, hence the VM IPs would depend on the node the VM runs on`),
+		}),
+		Entry("VMI requesting the default route on a layer3 network is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "topology": "layer3", "subnets": "10.10.0.0/16/24"}`),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), withAnnotation(config.IPRequestsAnnotation,
+					`{"randomnet": {"defaultRoute": true}}`)),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonUnsupportedIPRequests, ipRequestsAnnotationField,
+				`invalid default route request for interface "randomnet" on network `+
+					`"goodnet": IP requests are not supported on the layer3 network "goodnet": the default route `+
+					`gateway depends on the node the VM runs on, hence cannot be derived`),
+		}),
+		Entry("VMI requesting more than one IP per family is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyPrimaryNetworkNAD(nadName)},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "192.168.1.11")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "podnet" on network `+
+					`"primarynet": more than one IPv4 IP requested: [192.168.1.10 192.168.1.11]`),
+		}),
+		Entry("VMI requesting the default route through a gateway within the network subnets is accepted",
+			validatorTestConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+				request: vmiAdmissionRequest(
+					dummyVMI(nadName, withInterface("randomnet"), withAnnotation(config.IPRequestsAnnotation,
+						`{"randomnet": {"defaultRoute": true, "gateways": ["10.10.0.254"]}}`)),
+					admissionv1.Create,
+				),
+				expectedAdmissionResponse: allowedResponse("valid IP requests"),
+			}),
+		Entry("VMI requesting the default route without gateways on a secondary network whose subnet has no "+
+			"gateway is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), withAnnotation(config.IPRequestsAnnotation,
+					`{"randomnet": {"defaultRoute": true}}`)),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid default route request for interface "randomnet" on network "goodnet": the subnet `+
+					`10.10.0.0/24 of network "goodnet" has no gateway, hence the default route requires the gateways `+
+					`to be requested`),
+		}),
+		Entry("VMI requesting a gateway outside the network subnets is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), withAnnotation(config.IPRequestsAnnotation,
+					`{"randomnet": {"defaultRoute": true, "gateways": ["10.20.0.1"]}}`)),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid default route request for interface "randomnet" on `+
+					`network "goodnet": invalid gateway request: IP request 10.20.0.1 is not within the network subnets `+
+					`[10.10.0.0/24]`),
+		}),
+		Entry("VMI requesting the default route on several interfaces is rejected", validatorTestConfig{
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), withAnnotation(config.IPRequestsAnnotation,
+					`{"randomnet": {"defaultRoute": true}, "podnet": {"defaultRoute": true}}`)),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`"network.kubevirt.io/addresses" annotation requests the `+
+					`default route on several interfaces [podnet randomnet]`),
+		}),
+		Entry("VM whose template requests an IP outside the network subnets is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyPrimaryNetworkNAD(nadName)},
+			request: vmAdmissionRequest(
+				dummyVMWithTemplateAnnotations(nadName, ipRequestsAnnotation("podnet", "10.0.0.10")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, ipRequestsAnnotationField,
+				`invalid IP requests for interface "podnet" on network `+
+					`"primarynet": IP request 10.0.0.10 is not within the network subnets [192.168.0.0/16]`),
+		}),
+		Entry("VMI requesting an IP claimed by another VM on the same network is rejected", validatorTestConfig{
+			inputNADs:    []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			inputObjects: []client.Object{ipamClaimWithIPs("vm2", "randomnet", "goodnet", "10.10.0.5/24")},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonIPAlreadyHeld, ipRequestsAnnotationField,
+				`IP 10.10.0.5 requested for interface "randomnet" is already `+
+					`held by VM "vm2" on network "goodnet"`),
+		}),
+		Entry("VMI requesting an IP requested by another VMI on the same network is rejected", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyPrimaryNetworkNAD(nadName)},
+			inputObjects: []client.Object{
+				dummyVMI(nadName, withName("vm2"), WithIPRequests("podnet", "fd12:1234::200")),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "FD12:1234:0:0::200")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonIPAlreadyHeld, ipRequestsAnnotationField,
+				`IP fd12:1234::200 requested for interface "podnet" is already `+
+					`held by VM "vm2" on network "primarynet"`),
+		}),
+		Entry("VMI requesting an IP requested by a VMI of another namespace on the same NAD is rejected",
+			validatorTestConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+				inputObjects: []client.Object{
+					dummyVMI(nadName, withNamespace("ns2"), withInterface("randomnet"),
+						WithIPRequests("randomnet", "10.10.0.5")),
+				},
+				request: vmiAdmissionRequest(
+					dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+					admissionv1.Create,
+				),
+				expectedAdmissionResponse: deniedResponse(ReasonIPAlreadyHeld, ipRequestsAnnotationField,
+					`IP 10.10.0.5 requested for interface "randomnet" is already `+
+						`held by VM "ns2/vm1" on network "goodnet"`),
+			},
+		),
+		Entry("VMI requesting an IP claimed by a VM of another namespace on the same network is rejected",
+			validatorTestConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+				inputObjects: []client.Object{
+					ipamClaimInNamespace("ns2", ipamClaimWithIPs("vm1", "randomnet", "goodnet", "10.10.0.5/24")),
+				},
+				request: vmiAdmissionRequest(
+					dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+					admissionv1.Create,
+				),
+				expectedAdmissionResponse: deniedResponse(ReasonIPAlreadyHeld, ipRequestsAnnotationField,
+					`IP 10.10.0.5 requested for interface "randomnet" is already `+
+						`held by VM "ns2/vm1" on network "goodnet"`),
+			},
+		),
+		Entry("VMI requesting an IP requested by another VMI through another NAD of the same network is rejected",
+			validatorTestConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNADWithSubnets(nadName, "10.10.0.0/24"),
+					dummyNADWithSubnets("ns1/othernad", "10.10.0.0/24"),
+				},
+				inputObjects: []client.Object{
+					dummyVMI("ns1/othernad", withName("vm2"), withInterface("randomnet"),
+						WithIPRequests("randomnet", "10.10.0.5")),
+				},
+				request: vmiAdmissionRequest(
+					dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+					admissionv1.Create,
+				),
+				expectedAdmissionResponse: deniedResponse(ReasonIPAlreadyHeld, ipRequestsAnnotationField,
+					`IP 10.10.0.5 requested for interface "randomnet" is already `+
+						`held by VM "vm2" on network "goodnet"`),
+			},
+		),
+		Entry("VMI requesting an IP claimed by its own VM is accepted", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			inputObjects: []client.Object{
+				ipamClaimWithIPs("vm1", "randomnet", "goodnet", "10.10.0.5/24"),
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: allowedResponse("valid IP requests"),
+		}),
+		Entry("VMI requesting an IP held by another VM on a different network is accepted", validatorTestConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithSubnets(nadName, "10.10.0.0/24")},
+			inputObjects: []client.Object{
+				ipamClaimWithIPs("vm2", "othernet", "othernet", "10.10.0.5/24"),
+				dummyVMI("ns1/othernad", withName("vm2"), withInterface("randomnet"),
+					WithIPRequests("randomnet", "10.10.0.5")),
+			},
+			request: vmiAdmissionRequest(
+				dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+				admissionv1.Create,
+			),
+			expectedAdmissionResponse: allowedResponse("valid IP requests"),
+		}),
+		Entry("VMI requesting an IP requested by another VMI through a NAD of a different network is accepted",
+			validatorTestConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNADWithSubnets(nadName, "10.10.0.0/24"),
+					dummyNADWithConfig("ns1/othernad", `{"name": "othernet", "subnets": "10.10.0.0/24"}`),
+				},
+				inputObjects: []client.Object{
+					dummyVMI("ns1/othernad", withName("vm2"), withInterface("randomnet"),
+						WithIPRequests("randomnet", "10.10.0.5")),
+				},
+				request: vmiAdmissionRequest(
+					dummyVMI(nadName, withInterface("randomnet"), WithIPRequests("randomnet", "10.10.0.5")),
+					admissionv1.Create,
+				),
+				expectedAdmissionResponse: allowedResponse("valid IP requests"),
+			},
+		),
+		Entry("VMI update not changing the IP requests is accepted", validatorTestConfig{
+			request: vmiUpdateAdmissionRequest(
+				dummyVMI(nadName, WithIPRequests("podnet", "10.0.0.10")),
+				dummyVMI(nadName, WithIPRequests("podnet", "10.0.0.10")),
+			),
+			expectedAdmissionResponse: allowedResponse("IP requests not changed"),
+		}),
+	)
+})
+
+func allowedResponse(message string) admissionv1.AdmissionResponse {
+	return admissionv1.AdmissionResponse{
+		Allowed: true,
+		Result: &metav1.Status{
+			Message: message,
+			Code:    http.StatusOK,
+		},
+	}
+}
+
+func deniedResponse(reason Reason, field string, message string) admissionv1.AdmissionResponse {
+	return admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+			Details: statusDetails(reason, field, message),
+		},
+	}
+}
+
+func erroredResponse(code int32, reason Reason, message string) admissionv1.AdmissionResponse {
+	return admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+			Code:    code,
+			Details: statusDetails(reason, "", message),
+		},
+	}
+}
+
+func statusDetails(reason Reason, field string, message string) *metav1.StatusDetails {
+	return &metav1.StatusDetails{
+		Causes: []metav1.StatusCause{{Type: metav1.CauseType(reason), Message: message, Field: field}},
+	}
+}
+
+func dummyNADWithSubnets(nadName string, subnets string) *nadv1.NetworkAttachmentDefinition {
+	return dummyNADWithConfig(nadName, `{"name": "goodnet", "allowPersistentIPs": true, "subnets": "`+subnets+`"}`)
+}
+
+func dummyVMWithTemplateAnnotations(nadName string, annotations map[string]string) *virtv1.VirtualMachine {
+	vm := dummyVM(nadName)
+	vm.Spec.Template.ObjectMeta.Annotations = annotations
+	return vm
+}
+
+func ipRequestsAnnotation(logicalNetworkName string, ips ...string) map[string]string {
+	rawIPRequests, err := json.Marshal(map[string][]string{logicalNetworkName: ips})
+	if err != nil {
+		panic(err)
+	}
+	return map[string]string{config.IPRequestsAnnotation: string(rawIPRequests)}
+}
+
+func withInterface(ifaceName string) VMCreationOptions {
+	return func(vmi *virtv1.VirtualMachineInstance) error {
+		vmi.Spec.Domain.Devices.Interfaces = append(vmi.Spec.Domain.Devices.Interfaces, virtv1.Interface{Name: ifaceName})
+		return nil
+	}
+}
+
+func withAnnotation(key, value string) VMCreationOptions {
+	return func(vmi *virtv1.VirtualMachineInstance) error {
+		if vmi.Annotations == nil {
+			vmi.Annotations = map[string]string{}
+		}
+		vmi.Annotations[key] = value
+		return nil
+	}
+}
+
+func vmiAdmissionRequest(vmi *virtv1.VirtualMachineInstance, operation admissionv1.Operation) admission.Request {
+	return kubevirtAdmissionRequest("VirtualMachineInstance", vmi, nil, operation)
+}
+
+func vmiUpdateAdmissionRequest(oldVMI, vmi *virtv1.VirtualMachineInstance) admission.Request {
+	return kubevirtAdmissionRequest("VirtualMachineInstance", vmi, oldVMI, admissionv1.Update)
+}
+
+func vmAdmissionRequest(vm *virtv1.VirtualMachine, operation admissionv1.Operation) admission.Request {
+	return kubevirtAdmissionRequest("VirtualMachine", vm, nil, operation)
+}
+
+func kubevirtAdmissionRequest(
+	kind string,
+	obj client.Object,
+	oldObj client.Object,
+	operation admissionv1.Operation,
+) admission.Request {
+	request := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: kind},
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Operation: operation,
+			Object:    runtime.RawExtension{Raw: mustMarshal(obj)},
+		},
+	}
+	if oldObj != nil {
+		request.OldObject = runtime.RawExtension{Raw: mustMarshal(oldObj)}
+	}
+	return request
+}
+
+func mustMarshal(obj client.Object) []byte {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func withName(name string) VMCreationOptions {
+	return func(vmi *virtv1.VirtualMachineInstance) error {
+		vmi.Name = name
+		return nil
+	}
+}
+
+func ipamClaimWithIPs(vmName, logicalNetworkName, networkName string, ips ...string) *ipamclaimsapi.IPAMClaim {
+	return &ipamclaimsapi.IPAMClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      vmName + "." + logicalNetworkName,
+			Labels:    map[string]string{virtv1.VirtualMachineLabel: vmName},
+		},
+		Spec:   ipamclaimsapi.IPAMClaimSpec{Network: networkName},
+		Status: ipamclaimsapi.IPAMClaimStatus{IPs: ips},
+	}
+}
+
+func withIPIndexes(clientBuilder *fake.ClientBuilder) *fake.ClientBuilder {
+	return clientBuilder.
+		WithIndex(&ipamclaimsapi.IPAMClaim{}, ips.ClaimedIPsIndex, ips.ClaimedIPsIndexer).
+		WithIndex(&virtv1.VirtualMachineInstance{}, ips.RequestedIPsIndex, ips.RequestedIPsIndexer)
+}
+
+func withNamespace(namespace string) VMCreationOptions {
+	return func(vmi *virtv1.VirtualMachineInstance) error {
+		vmi.Namespace = namespace
+		return nil
+	}
+}
+
+func ipamClaimInNamespace(namespace string, ipamClaim *ipamclaimsapi.IPAMClaim) *ipamclaimsapi.IPAMClaim {
+	ipamClaim.Namespace = namespace
+	return ipamClaim
+}
@@ -0,0 +1,153 @@
+package ipamclaimswebhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	netutils "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/utils"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/claims"
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+const virtLauncherAppName = "virt-launcher"
+
+// vmAnnotation is set by KubeVirt on the launcher pods, holding the name of their VMI
+const vmAnnotation = "kubevirt.io/domain"
+
+// validateLauncherPod ensures the pod is the virt-launcher pod KubeVirt created for the VMI: the VMI domain
+// annotation alone can be set by anyone able to create pods, thus is not enough to hand over the VMI claims.
+func validateLauncherPod(pod *corev1.Pod, vmi *virtv1.VirtualMachineInstance) error {
+	podKey := podReference(pod)
+	vmiKey := client.ObjectKeyFromObject(vmi).String()
+
+	if !isControlledByVMI(pod, vmi) {
+		return ValidationError{
+			Reason: ReasonNotLauncherPod,
+			Field:  "metadata.ownerReferences",
+			Message: fmt.Sprintf("pod %q is not the virt-launcher pod of VMI %q: it is not controlled by the VMI",
+				podKey, vmiKey),
+		}
+	}
+	if pod.Labels[virtv1.AppLabel] != virtLauncherAppName || pod.Labels[virtv1.CreatedByLabel] != string(vmi.UID) {
+		return ValidationError{
+			Reason: ReasonNotLauncherPod,
+			Field:  "metadata.labels",
+			Message: fmt.Sprintf("pod %q is not the virt-launcher pod of VMI %q: its %q and %q labels do not match",
+				podKey, vmiKey, virtv1.AppLabel, virtv1.CreatedByLabel),
+		}
+	}
+	return nil
+}
+
+func isControlledByVMI(pod *corev1.Pod, vmi *virtv1.VirtualMachineInstance) bool {
+	controller := metav1.GetControllerOf(pod)
+	if controller == nil {
+		return false
+	}
+	ownerGV, err := schema.ParseGroupVersion(controller.APIVersion)
+	if err != nil {
+		return false
+	}
+	return ownerGV.Group == virtv1.VirtualMachineInstanceGroupVersionKind.Group &&
+		controller.Kind == virtv1.VirtualMachineInstanceGroupVersionKind.Kind &&
+		controller.UID == vmi.UID
+}
+
+// validateIPAMClaimReferences ensures the IPAMClaims the pod already references are owned by the pod VM: each
+// reference must be a claim name composed for one of the VM networks, and an existing claim must be controlled by
+// the VM (or the VMI, when it has no VM) - the owner UID tells the VM apart from a former VM of the same name.
+func validateIPAMClaimReferences(
+	ctx context.Context,
+	cli client.Client,
+	vmi *virtv1.VirtualMachineInstance,
+	pod *corev1.Pod,
+	networkSelectionElements []*v1.NetworkSelectionElement,
+) error {
+	ownerUID := claims.OwnerReferenceFor(vmi, vmiOwningVM(vmi)).UID
+	for _, ipamClaimName := range podIPAMClaimReferences(pod, networkSelectionElements) {
+		if !slices.Contains(vmiClaimNames(vmi), ipamClaimName) {
+			return ipamClaimNotOwnedError(pod, vmi, ipamClaimName)
+		}
+
+		ipamClaim := &ipamclaimsapi.IPAMClaim{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: vmi.Namespace, Name: ipamClaimName}, ipamClaim); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to access the IPAMClaim %q: %w", ipamClaimName, err)
+		}
+		if controller := metav1.GetControllerOf(ipamClaim); controller == nil || controller.UID != ownerUID {
+			return ipamClaimNotOwnedError(pod, vmi, ipamClaimName)
+		}
+	}
+	return nil
+}
+
+// podIPAMClaimReferences returns the IPAMClaims referenced by the pod network selection elements, multus default
+// network and legacy OVN primary network annotations
+func podIPAMClaimReferences(pod *corev1.Pod, networkSelectionElements []*v1.NetworkSelectionElement) []string {
+	var ipamClaimNames []string
+	for _, networkSelectionElement := range networkSelectionElements {
+		if networkSelectionElement.IPAMClaimReference != "" {
+			ipamClaimNames = append(ipamClaimNames, networkSelectionElement.IPAMClaimReference)
+		}
+	}
+
+	// an unparsable multus default network annotation is reported when the annotation is mutated
+	if rawDefaultNetwork, exists := pod.Annotations[config.MultusDefaultNetAnnotation]; exists {
+		defaultNetworkSelectionElements, err := netutils.ParseNetworkAnnotation(rawDefaultNetwork, pod.Namespace)
+		if err == nil {
+			for _, networkSelectionElement := range defaultNetworkSelectionElements {
+				if networkSelectionElement.IPAMClaimReference != "" {
+					ipamClaimNames = append(ipamClaimNames, networkSelectionElement.IPAMClaimReference)
+				}
+			}
+		}
+	}
+
+	if ipamClaimName := pod.Annotations[config.OVNPrimaryNetworkIPAMClaimAnnotation]; ipamClaimName != "" {
+		ipamClaimNames = append(ipamClaimNames, ipamClaimName)
+	}
+	return ipamClaimNames
+}
+
+// vmiClaimNames returns the names of the IPAMClaims the VMI networks may hold
+func vmiClaimNames(vmi *virtv1.VirtualMachineInstance) []string {
+	claimNames := make([]string, 0, len(vmi.Spec.Networks))
+	for _, network := range vmi.Spec.Networks {
+		claimNames = append(claimNames, claims.ComposeKey(vmi.Name, network.Name))
+	}
+	return claimNames
+}
+
+func ipamClaimNotOwnedError(pod *corev1.Pod, vmi *virtv1.VirtualMachineInstance, ipamClaimName string) error {
+	return ValidationError{
+		Reason: ReasonIPAMClaimNotOwned,
+		Message: fmt.Sprintf("pod %q references IPAMClaim %q which is not owned by VM %q",
+			podReference(pod), ipamClaimName, vmi.Name),
+	}
+}
+
+// podReference identifies the pod in the admission messages; the pods created with a generated name have no name
+// yet when admitted for creation, hence are identified by their name prefix.
+func podReference(pod *corev1.Pod) string {
+	name := pod.Name
+	if name == "" {
+		name = pod.GenerateName
+	}
+	return types.NamespacedName{Namespace: pod.Namespace, Name: name}.String()
+}
@@ -0,0 +1,76 @@
+package ipamclaimswebhook
+
+import (
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const annotationsPath = "/metadata/annotations"
+
+// jsonPointerEscaper escapes an annotation key into a JSON pointer (RFC 6901) reference token
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// annotationsPatch collects the annotations the webhook sets on the pod, and renders the JSON patch operations
+// setting them - instead of diffing the whole serialized pod, since the webhook only ever mutates annotations
+type annotationsPatch struct {
+	original map[string]string
+	// keys holds the annotations to set, in the order they were first set
+	keys   []string
+	values map[string]string
+}
+
+func newAnnotationsPatch(pod *corev1.Pod) *annotationsPatch {
+	return &annotationsPatch{original: pod.Annotations, values: map[string]string{}}
+}
+
+func (p *annotationsPatch) set(key, value string) {
+	if _, isSet := p.values[key]; !isSet {
+		p.keys = append(p.keys, key)
+	}
+	p.values[key] = value
+}
+
+// isEmpty reports whether no annotation was set, as opposed to annotations set to their original value
+func (p *annotationsPatch) isEmpty() bool {
+	return len(p.keys) == 0
+}
+
+// operations returns the JSON patch operations setting the annotations whose value changed
+func (p *annotationsPatch) operations() []jsonpatch.JsonPatchOperation {
+	var operations []jsonpatch.JsonPatchOperation
+	if p.original == nil && !p.isEmpty() {
+		operations = append(operations, jsonpatch.NewOperation("add", annotationsPath, map[string]string{}))
+	}
+	for _, key := range p.keys {
+		value := p.values[key]
+		originalValue, exists := p.original[key]
+		if exists && originalValue == value {
+			continue
+		}
+		operation := "add"
+		if exists {
+			operation = "replace"
+		}
+		operations = append(operations,
+			jsonpatch.NewOperation(operation, annotationsPath+"/"+jsonPointerEscaper.Replace(key), value))
+	}
+	return operations
+}
+
+// response returns the admission response patching the pod, as admission.PatchResponseFromRaw does
+func (p *annotationsPatch) response() admission.Response {
+	return admission.Response{
+		Patches: p.operations(),
+		AdmissionResponse: admissionv1.AdmissionResponse{
+			Allowed:   true,
+			PatchType: ptr.To(admissionv1.PatchTypeJSONPatch),
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package ipamclaimswebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"gomodules.xyz/jsonpatch/v2"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+var _ = Describe("pod annotations patch", func() {
+	It("adds the missing annotations and replaces the changed ones", func() {
+		patch := newAnnotationsPatch(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{v1.NetworkAttachmentAnnot: `[{"name":"net1"}]`},
+		}})
+		patch.set(v1.NetworkAttachmentAnnot, `[{"name":"net1","ipam-claim-reference":"vm1.iface1"}]`)
+		patch.set(config.OVNPrimaryNetworkIPAMClaimAnnotation, "vm1.pod")
+
+		Expect(patch.operations()).To(Equal([]jsonpatch.JsonPatchOperation{
+			{
+				Operation: "replace",
+				Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+				Value:     `[{"name":"net1","ipam-claim-reference":"vm1.iface1"}]`,
+			},
+			{
+				Operation: "add",
+				Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
+				Value:     "vm1.pod",
+			},
+		}))
+	})
+
+	It("skips the annotations set to their original value", func() {
+		patch := newAnnotationsPatch(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{config.OVNPrimaryNetworkIPAMClaimAnnotation: "vm1.pod"},
+		}})
+		patch.set(config.OVNPrimaryNetworkIPAMClaimAnnotation, "vm1.pod")
+
+		Expect(patch.isEmpty()).To(BeFalse())
+		Expect(patch.operations()).To(BeEmpty())
+	})
+
+	It("renders a single operation per annotation, holding its last value", func() {
+		patch := newAnnotationsPatch(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"kubevirt.io/domain": "vm1"},
+		}})
+		patch.set(config.MultusDefaultNetAnnotation, `[{"name":"default"}]`)
+		patch.set(config.MultusDefaultNetAnnotation, `[{"name":"default","ipam-claim-reference":"vm1.pod"}]`)
+
+		Expect(patch.operations()).To(Equal([]jsonpatch.JsonPatchOperation{
+			{
+				Operation: "add",
+				Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+				Value:     `[{"name":"default","ipam-claim-reference":"vm1.pod"}]`,
+			},
+		}))
+	})
+
+	It("creates the annotations of a pod without any", func() {
+		patch := newAnnotationsPatch(&corev1.Pod{})
+		patch.set(config.OVNPrimaryNetworkIPAMClaimAnnotation, "vm1.pod")
+
+		Expect(patch.operations()).To(Equal([]jsonpatch.JsonPatchOperation{
+			{Operation: "add", Path: "/metadata/annotations", Value: map[string]string{}},
+			{Operation: "add", Path: "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim", Value: "vm1.pod"},
+		}))
+	})
+})
+
+const benchmarkNetworks = `[{"name":"net1","namespace":"ns1","ipam-claim-reference":"vm1.iface1"}]`
+
+// largeLauncherPod returns a launcher pod holding as many volumes and sidecars as the larger VMs do
+func largeLauncherPod() *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "virt-launcher-vm1-abcde",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				"kubevirt.io/domain":      "vm1",
+				v1.NetworkAttachmentAnnot: `[{"name":"net1","namespace":"ns1"}]`,
+			},
+		},
+	}
+	for i := range 50 {
+		volumeName := fmt.Sprintf("volume-%d", i)
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: volumeName},
+			},
+		})
+	}
+	for i := range 10 {
+		container := corev1.Container{Name: fmt.Sprintf("sidecar-%d", i), Image: "quay.io/kubevirt/sidecar:latest"}
+		for _, volume := range pod.Spec.Volumes {
+			container.VolumeMounts = append(container.VolumeMounts,
+				corev1.VolumeMount{Name: volume.Name, MountPath: "/var/run/" + volume.Name})
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, container)
+	}
+	return pod
+}
+
+func BenchmarkAnnotationsPatch(b *testing.B) {
+	pod := largeLauncherPod()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		patch := newAnnotationsPatch(pod)
+		patch.set(v1.NetworkAttachmentAnnot, benchmarkNetworks)
+		patch.set(config.OVNPrimaryNetworkIPAMClaimAnnotation, "vm1.pod")
+		if response := patch.response(); len(response.Patches) != 2 {
+			b.Fatalf("expected 2 patches, got %v", response.Patches)
+		}
+	}
+}
+
+// BenchmarkPatchResponseFromRaw measures the former approach: diffing the serialized mutated pod against the
+// admission request object
+func BenchmarkPatchResponseFromRaw(b *testing.B) {
+	pod := largeLauncherPod()
+	rawPod, err := json.Marshal(pod)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		newPod := pod.DeepCopy()
+		newPod.Annotations[v1.NetworkAttachmentAnnot] = benchmarkNetworks
+		newPod.Annotations[config.OVNPrimaryNetworkIPAMClaimAnnotation] = "vm1.pod"
+		marshaledPod, err := json.Marshal(newPod)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if response := admission.PatchResponseFromRaw(rawPod, marshaledPod); len(response.Patches) != 2 {
+			b.Fatalf("expected 2 patches, got %v", response.Patches)
+		}
+	}
+}
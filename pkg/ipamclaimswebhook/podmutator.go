@@ -17,13 +17,13 @@ limitations under the License.
 package ipamclaimswebhook
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"net"
 	"reflect"
-	"strings"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -33,6 +33,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
@@ -43,25 +44,47 @@ import (
 	"github.com/kubevirt/ipam-extensions/pkg/claims"
 	"github.com/kubevirt/ipam-extensions/pkg/config"
 	"github.com/kubevirt/ipam-extensions/pkg/ips"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
 	"github.com/kubevirt/ipam-extensions/pkg/udn"
 )
 
-// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=fail,groups="",resources=pods,verbs=create;update,versions=v1,name=ipam-claims.k8s.cni.cncf.io,admissionReviewVersions=v1,sideEffects=None
+// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=fail,groups="",resources=pods,verbs=create;update,versions=v1,name=ipam-claims.k8s.cni.cncf.io,admissionReviewVersions=v1,sideEffects=NoneOnDryRun
 //nolint:lll
 
 // IPAMClaimsValet annotates Pods
 type IPAMClaimsValet struct {
 	client.Client
+	// apiReader reads the VMIs missing from the informer cache from the API server
+	apiReader              client.Reader
 	decoder                admission.Decoder
+	nadConfigs             *nads.ConfigCache
+	primaryNetworks        *udn.PrimaryNetworkFinder
 	defaultNetNADNamespace string
+	vmiResolution          VMIResolutionPolicy
+	recorder               record.EventRecorder
+	// defaultNetworkRequesters may create launcher pods already requesting the multus default network
+	defaultNetworkRequesters *IdentityAllowlist
 }
 
+// EventSource is the component reported by the controller and webhook Events
+const EventSource = "kubevirt-ipam-controller"
+
 type Option func(*IPAMClaimsValet)
 
-func NewIPAMClaimsValet(manager manager.Manager, opts ...Option) *IPAMClaimsValet {
+func NewIPAMClaimsValet(
+	manager manager.Manager,
+	nadConfigs *nads.ConfigCache,
+	primaryNetworks *udn.PrimaryNetworkFinder,
+	opts ...Option,
+) *IPAMClaimsValet {
 	claimsManager := &IPAMClaimsValet{
-		decoder: admission.NewDecoder(manager.GetScheme()),
-		Client:  manager.GetClient(),
+		decoder:         admission.NewDecoder(manager.GetScheme()),
+		Client:          manager.GetClient(),
+		apiReader:       manager.GetAPIReader(),
+		nadConfigs:      nadConfigs,
+		primaryNetworks: primaryNetworks,
+		vmiResolution:   VMIResolutionPolicyStrict,
+		recorder:        manager.GetEventRecorderFor(EventSource),
 	}
 	for _, opt := range opts {
 		opt(claimsManager)
@@ -75,17 +98,78 @@ func WithDefaultNetNADNamespace(namespace string) Option {
 	}
 }
 
+// WithVMIResolutionPolicy sets the policy applying to the launcher pods whose VMI cannot be resolved, unless
+// overridden by their namespace
+func WithVMIResolutionPolicy(policy VMIResolutionPolicy) Option {
+	return func(ipamValet *IPAMClaimsValet) {
+		ipamValet.vmiResolution = policy
+	}
+}
+
+// WithDefaultNetworkRequesters allows the identities to create launcher pods already requesting the multus default
+// network; their request is merged with the VM primary network requests instead of being rejected
+func WithDefaultNetworkRequesters(allowlist *IdentityAllowlist) Option {
+	return func(ipamValet *IPAMClaimsValet) {
+		ipamValet.defaultNetworkRequesters = allowlist
+	}
+}
+
+// WithAPIReader sets the reader of the VMIs missing from the informer cache
+func WithAPIReader(apiReader client.Reader) Option {
+	return func(ipamValet *IPAMClaimsValet) {
+		ipamValet.apiReader = apiReader
+	}
+}
+
+// WithEventRecorder sets the recorder of the Events reporting the launcher pods admitted without mutation
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return func(ipamValet *IPAMClaimsValet) {
+		ipamValet.recorder = recorder
+	}
+}
+
 func (a *IPAMClaimsValet) Handle(ctx context.Context, request admission.Request) admission.Response {
+	warnings := &admissionWarnings{}
+	return a.handle(ctx, request, warnings).WithWarnings(*warnings...)
+}
+
+// handle mutates the launcher pod, collecting the warnings on the network requests which cannot be honored
+func (a *IPAMClaimsValet) handle(
+	ctx context.Context,
+	request admission.Request,
+	warnings *admissionWarnings,
+) admission.Response {
 	log := logf.FromContext(ctx)
 
 	pod := &corev1.Pod{}
 	if err := a.decoder.Decode(request, pod); err != nil {
-		return admission.Errored(http.StatusBadRequest, err)
+		return errorResponse(RequestError{Reason: ReasonMalformedRequest, Err: err})
 	}
 
 	log.V(1).Info("webhook handling event")
 
-	vmName, hasVMAnnotation := pod.Annotations["kubevirt.io/domain"]
+	// the network requests are defined on the pod creation; updates are validated not to change them, the launcher
+	// pod being identified out of the old pod as well, so its VM annotation cannot be dropped along with them
+	if request.Operation == admissionv1.Update {
+		oldPod := &corev1.Pod{}
+		if err := a.decoder.DecodeRaw(request.OldObject, oldPod); err != nil {
+			return errorResponse(RequestError{Reason: ReasonMalformedRequest, Err: err})
+		}
+		_, oldPodHasVMAnnotation := oldPod.Annotations[vmAnnotation]
+		_, hasVMAnnotation := pod.Annotations[vmAnnotation]
+		if !oldPodHasVMAnnotation && !hasVMAnnotation {
+			log.V(1).Info(
+				"does not have the kubevirt VM annotation",
+			)
+			return admission.Allowed("not a VM")
+		}
+		if err := validatePodUpdate(oldPod, pod); err != nil {
+			return errorResponse(err)
+		}
+		return admission.Allowed("network requests not changed")
+	}
+
+	vmName, hasVMAnnotation := pod.Annotations[vmAnnotation]
 	if !hasVMAnnotation {
 		log.V(1).Info(
 			"does not have the kubevirt VM annotation",
@@ -97,39 +181,90 @@ func (a *IPAMClaimsValet) Handle(ctx context.Context, request admission.Request)
 	if err != nil {
 		var goodTypeOfError *v1.NoK8sNetworkError
 		if !errors.As(err, &goodTypeOfError) {
-			return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to parse pod network selection elements"))
+			return errorResponse(RequestError{
+				Reason: ReasonMalformedRequest,
+				Err:    fmt.Errorf("failed to parse pod network selection elements"),
+			})
 		}
 	}
 
-	primaryNetwork, err := primaryNetworkConfig(a.Client, ctx, pod.Namespace)
-	if err != nil {
-		return admission.Errored(http.StatusInternalServerError, err)
+	// an ambiguous or unreadable primary network only fails the VMs attached to it, which is known once the VMI is
+	// retrieved
+	primaryNetwork, primaryNetworkErr := primaryNetworkConfig(a.Client, ctx, a.primaryNetworks, pod.Namespace)
+	if primaryNetworkErr != nil && !udn.IsPrimaryNetworkError(primaryNetworkErr) {
+		return errorResponse(primaryNetworkErr)
+	}
+	// one of the NADs the primary network lookup skips, since their configuration cannot be parsed, may be the
+	// namespace primary network
+	var invalidNADNames []string
+	if primaryNetwork == nil && primaryNetworkErr == nil {
+		invalidNADNames, err = udn.FindInvalidNADs(ctx, a.Client, pod.Namespace)
+		if err != nil {
+			return errorResponse(err)
+		}
 	}
 
-	if len(networkSelectionElements) == 0 && primaryNetwork == nil {
+	_, hasMultusDefaultNetwork := pod.Annotations[config.MultusDefaultNetAnnotation]
+	if len(networkSelectionElements) == 0 && primaryNetwork == nil && primaryNetworkErr == nil &&
+		len(invalidNADNames) == 0 && !hasMultusDefaultNetwork {
 		return admission.Allowed("no mutation required")
 	}
 
 	vmKey := types.NamespacedName{Namespace: pod.Namespace, Name: vmName}
 	vmi := &virtv1.VirtualMachineInstance{}
-	if err := getAndRetryOnNotFound(ctx, a.Client, vmKey, vmi); err != nil {
-		return admission.Errored(http.StatusInternalServerError, fmt.Errorf(
+	if err := getVMI(ctx, a.Client, a.apiReader, vmKey, vmi); err != nil {
+		reason := ReasonInternalError
+		if k8serrors.IsNotFound(err) {
+			reason = ReasonVMINotFound
+		}
+		return a.handleUnresolvedVMI(ctx, pod, vmKey, ServerError{Reason: reason, Err: fmt.Errorf(
 			"failed to access the VMI running in pod %q: %w",
-			types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}.String(),
+			podReference(pod),
 			err,
-		))
+		)}, warnings)
+	}
+
+	if err := validateLauncherPod(pod, vmi); err != nil {
+		return errorResponse(err)
+	}
+	if err := validateIPAMClaimReferences(ctx, a.Client, vmi, pod, networkSelectionElements); err != nil {
+		return errorResponse(err)
 	}
 
-	var newPod *corev1.Pod
-	hasChangedNetworkSelectionElements, err :=
-		ensureIPAMClaimRefAtNetworkSelectionElements(ctx, a.Client, vmi, networkSelectionElements)
+	if primaryNetworkErr != nil {
+		if vmiPodNetwork(vmi) != nil {
+			return errorResponse(primaryNetworkErr)
+		}
+		log.Info(
+			"ignoring the primary network lookup failure since the VM is not attached to it",
+			"reason", primaryNetworkErr.Error(),
+		)
+	}
+	if len(invalidNADNames) > 0 && vmiPodNetwork(vmi) != nil {
+		return errorResponse(unresolvedPrimaryNetworkError(pod.Namespace, invalidNADNames))
+	}
+
+	patch := newAnnotationsPatch(pod)
+	ipamClaims := ipamClaimRequests{}
+	hasChangedNetworkSelectionElements, err := ensureIPAMClaimRefAtNetworkSelectionElements(
+		ctx, a.Client, a.nadConfigs, vmi, networkSelectionElements, ipamClaims, warnings)
 	if err != nil {
-		return admission.Errored(http.StatusInternalServerError, err)
+		return errorResponse(err)
 	}
 	if hasChangedNetworkSelectionElements {
-		newPod = pod.DeepCopy()
-		if err := updatePodSelectionElements(newPod, networkSelectionElements); err != nil {
-			return admission.Errored(http.StatusInternalServerError, err)
+		if err := updatePodSelectionElements(patch, networkSelectionElements); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	multusDefaultNetworkSelectionElement, err :=
+		ensureIPAMClaimRefAtMultusDefaultNetwork(ctx, a.Client, a.nadConfigs, vmi, pod, ipamClaims, warnings)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if multusDefaultNetworkSelectionElement != nil {
+		if err := definePodMultusDefaultNetworkAnnotation(patch, multusDefaultNetworkSelectionElement); err != nil {
+			return errorResponse(err)
 		}
 	}
 
@@ -138,83 +273,91 @@ func (a *IPAMClaimsValet) Handle(ctx context.Context, request admission.Request)
 			"primary network attachment found",
 			"network", primaryNetwork.Name,
 		)
-		primaryUDNInterface, err := findPrimaryUDNInterface(ctx, vmi, primaryNetwork)
-		if err != nil {
-			return admission.Errored(http.StatusInternalServerError,
-				fmt.Errorf("failed looking for primary user defined IPAMClaim name: %v", err))
-		}
-
+		primaryUDNInterface := findPrimaryUDNInterface(ctx, vmi, warnings)
 		if primaryUDNInterface != nil {
-			if err := validateDefaultMultusNetworkRequest(pod, request.Operation); err != nil {
-				if isValidationError(err) {
-					return admission.Denied(err.Error())
-				}
-				return admission.Errored(http.StatusInternalServerError, err)
+			primaryUDNIPRequests, err := ips.VmiInterfaceIPRequests(vmi, primaryUDNInterface.Name, primaryNetwork)
+			if err != nil {
+				return errorResponse(ipRequestsError(err))
 			}
-
-			if newPod == nil {
-				newPod = pod.DeepCopy()
+			if len(primaryUDNIPRequests) > 0 && !primaryNetwork.AllowPersistentIPs {
+				warnings.addNotPersistedIPRequests(primaryUDNInterface.Name, primaryNetwork.Name)
 			}
-
-			primaryUDNIPRequests, err := ips.VmiInterfaceIPRequests(vmi, primaryUDNInterface.Name, primaryNetwork)
+			primaryUDNGateways, err := ips.VmiInterfaceGatewayRequests(vmi, primaryUDNInterface.Name, primaryNetwork)
 			if err != nil {
-				return admission.Errored(http.StatusInternalServerError, err)
+				return errorResponse(ipRequestsError(err))
+			}
+
+			// the user MAC, IP and gateway requests are honored whatever the network persistent IPs setting, while the
+			// IPAMClaim is only referenced when the network allows persistent IPs
+			var ipamClaimName string
+			if primaryNetwork.AllowPersistentIPs {
+				ipamClaimName = claims.ComposeKey(vmi.Name, primaryUDNInterface.Name)
+				ipamClaims[primaryUDNInterface.Name] = primaryNetwork.Name
 			}
+			hasUserRequests := len(primaryUDNIPRequests) > 0 || len(primaryUDNGateways) > 0 ||
+				primaryUDNInterface.MacAddress != ""
 
 			primaryUDNNetworkSelectionElement := multusDefaultNetworkAnnotation(
 				a.defaultNetNADNamespace,
 				primaryUDNInterface.MacAddress,
-				claims.ComposeKey(vmi.Name, primaryUDNInterface.Name),
+				ipamClaimName,
+				primaryUDNGateways,
 				primaryUDNIPRequests...,
 			)
+			_, hasMultusDefaultNetwork := pod.Annotations[config.MultusDefaultNetAnnotation]
+			mergesDefaultNetworkRequest := (ipamClaimName != "" || hasUserRequests) && hasMultusDefaultNetwork &&
+				a.defaultNetworkRequesters.allows(request.UserInfo)
+			if mergesDefaultNetworkRequest {
+				primaryUDNNetworkSelectionElement, err = mergeDefaultNetworkRequest(pod, primaryUDNNetworkSelectionElement)
+				if err != nil {
+					return errorResponse(err)
+				}
+			} else if ipamClaimName != "" || hasUserRequests {
+				if err := validateDefaultMultusNetworkRequest(pod); err != nil {
+					return errorResponse(err)
+				}
+			}
 
 			// TODO: once we have deprecated the ipam-claim dedicated OVN-K annotation, we can drop the if below
-			if len(primaryUDNIPRequests) > 0 || primaryUDNInterface.MacAddress != "" {
-				if err := definePodMultusDefaultNetworkAnnotation(newPod, primaryUDNNetworkSelectionElement); err != nil {
-					return admission.Errored(http.StatusInternalServerError, err)
+			if hasUserRequests || mergesDefaultNetworkRequest {
+				if err := definePodMultusDefaultNetworkAnnotation(patch, primaryUDNNetworkSelectionElement); err != nil {
+					return errorResponse(err)
 				}
 			}
 
-			// Set the legacy OVN primary network IPAM claim annotation for backwards compatibility
-			updatePodWithOVNPrimaryNetworkIPAMClaimAnnotation(newPod, claims.ComposeKey(vmi.Name, primaryUDNInterface.Name))
+			if ipamClaimName != "" {
+				// Set the legacy OVN primary network IPAM claim annotation for backwards compatibility
+				updatePodWithOVNPrimaryNetworkIPAMClaimAnnotation(patch, ipamClaimName)
+			}
 		}
 	}
 
-	if newPod != nil {
-		if reflect.DeepEqual(newPod, pod) {
-			return admission.Allowed("mutation not needed")
+	if len(ipamClaims) > 0 {
+		if err := a.ensureIPAMClaims(ctx, request, vmi, ipamClaims); err != nil {
+			return errorResponse(err)
 		}
+	}
 
-		marshaledPod, err := json.Marshal(newPod)
-		if err != nil {
-			return admission.Errored(http.StatusInternalServerError, err)
+	if !patch.isEmpty() {
+		response := patch.response()
+		if len(response.Patches) == 0 {
+			return admission.Allowed("mutation not needed")
 		}
 
-		log.V(1).Info("new pod annotations", "pod", newPod.Annotations)
-		return admission.PatchResponseFromRaw(request.Object.Raw, marshaledPod)
+		log.V(1).Info("new pod annotations", "patches", response.Patches)
+		return response
 	}
 
 	return admission.Allowed("carry on")
 }
 
-// ValidationError represents a validation failure (should result in admission.Denied)
-type ValidationError struct {
-	Message string
-}
-
-func (e ValidationError) Error() string {
-	return e.Message
-}
+var multusDefaultNetworkAnnotationField = fmt.Sprintf("metadata.annotations[%s]", config.MultusDefaultNetAnnotation)
 
-// isValidationError checks if the error is a ValidationError
-func isValidationError(err error) bool {
-	var validationErr ValidationError
-	return errors.As(err, &validationErr)
-}
-
-func validateDefaultMultusNetworkRequest(pod *corev1.Pod, operation admissionv1.Operation) error {
-	if _, exists := pod.Annotations[config.MultusDefaultNetAnnotation]; operation == admissionv1.Create && exists {
+func validateDefaultMultusNetworkRequest(pod *corev1.Pod) error {
+	if _, exists := pod.Annotations[config.MultusDefaultNetAnnotation]; exists {
 		return ValidationError{
+			Reason: ReasonDefaultNetworkNotAllowed,
+			Field:  multusDefaultNetworkAnnotationField,
 			Message: fmt.Sprintf(
 				"multus default network annotation %q is not allowed on pod creation",
 				config.MultusDefaultNetAnnotation,
@@ -224,57 +367,104 @@ func validateDefaultMultusNetworkRequest(pod *corev1.Pod, operation admissionv1.
 	return nil
 }
 
-// returns the names of the kubevirt VM networks indexed by their NAD name
-func vmiSecondaryNetworks(vmi *virtv1.VirtualMachineInstance) map[string]string {
-	indexedSecondaryNetworks := map[string]string{}
-	for _, network := range vmi.Spec.Networks {
-		if network.Multus == nil {
-			continue
+// parseMultusDefaultNetwork returns the single network selection element of the multus default network annotation
+func parseMultusDefaultNetwork(rawDefaultNetwork string, podNamespace string) (*v1.NetworkSelectionElement, error) {
+	defaultNetworkSelectionElements, err := netutils.ParseNetworkAnnotation(rawDefaultNetwork, podNamespace)
+	if err != nil {
+		return nil, RequestError{
+			Reason: ReasonMalformedRequest,
+			Err:    fmt.Errorf("failed to parse the multus default network annotation: %w", err),
 		}
-		if network.Multus.Default {
-			continue
+	}
+	if len(defaultNetworkSelectionElements) != 1 {
+		return nil, RequestError{
+			Reason: ReasonMalformedRequest,
+			Err: fmt.Errorf(
+				"expected a single multus default network selection element, found %d",
+				len(defaultNetworkSelectionElements),
+			),
 		}
+	}
+	return defaultNetworkSelectionElements[0], nil
+}
 
-		nadName := network.Multus.NetworkName // NAD name must be formatted in <ns>/<name> format
-		if !strings.Contains(network.Multus.NetworkName, "/") {
-			nadName = fmt.Sprintf("%s/%s", vmi.Namespace, network.Multus.NetworkName)
+// mergeDefaultNetworkRequest merges the VM primary network requests into the multus default network the pod already
+// requests, refusing a request for another network or conflicting with the VM requests
+func mergeDefaultNetworkRequest(
+	pod *corev1.Pod,
+	vmRequest *v1.NetworkSelectionElement,
+) (*v1.NetworkSelectionElement, error) {
+	networkSelectionElement, err := parseMultusDefaultNetwork(pod.Annotations[config.MultusDefaultNetAnnotation],
+		pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if networkSelectionElement.Namespace != vmRequest.Namespace || networkSelectionElement.Name != vmRequest.Name {
+		return nil, ValidationError{
+			Reason: ReasonConflictingNetworkRequests,
+			Field:  multusDefaultNetworkAnnotationField,
+			Message: fmt.Sprintf("multus default network annotation requests network %s/%s instead of the primary "+
+				"network %s/%s", networkSelectionElement.Namespace, networkSelectionElement.Name,
+				vmRequest.Namespace, vmRequest.Name),
 		}
-		indexedSecondaryNetworks[nadName] = network.Name
 	}
 
-	return indexedSecondaryNetworks
+	if vmRequest.MacRequest != "" {
+		if _, err := ensureMACRequest(networkSelectionElement, vmRequest.MacRequest); err != nil {
+			return nil, err
+		}
+	}
+	if len(vmRequest.IPRequest) > 0 {
+		if _, err := ensureIPRequests(networkSelectionElement, vmRequest.IPRequest); err != nil {
+			return nil, err
+		}
+	}
+	if len(vmRequest.GatewayRequest) > 0 {
+		if _, err := ensureGatewayRequest(networkSelectionElement, vmRequest.GatewayRequest); err != nil {
+			return nil, err
+		}
+	}
+	if vmRequest.IPAMClaimReference != "" {
+		if _, err := ensureIPAMClaimReference(networkSelectionElement, vmRequest.IPAMClaimReference); err != nil {
+			return nil, err
+		}
+	}
+	return networkSelectionElement, nil
 }
 
-func updatePodSelectionElements(pod *corev1.Pod, networks []*v1.NetworkSelectionElement) error {
+func updatePodSelectionElements(patch *annotationsPatch, networks []*v1.NetworkSelectionElement) error {
 	newNets, err := json.Marshal(networks)
 	if err != nil {
 		return err
 	}
-	pod.Annotations[v1.NetworkAttachmentAnnot] = string(newNets)
+	patch.set(v1.NetworkAttachmentAnnot, string(newNets))
 	return nil
 }
 
-func definePodMultusDefaultNetworkAnnotation(pod *corev1.Pod, networkConfig *v1.NetworkSelectionElement) error {
+func definePodMultusDefaultNetworkAnnotation(patch *annotationsPatch, networkConfig *v1.NetworkSelectionElement) error {
 	rawNetData, err := json.Marshal([]*v1.NetworkSelectionElement{networkConfig})
 	if err != nil {
 		return fmt.Errorf("failed to marshal network configuration: %w", err)
 	}
-	pod.Annotations[config.MultusDefaultNetAnnotation] = string(rawNetData)
+	patch.set(config.MultusDefaultNetAnnotation, string(rawNetData))
 	return nil
 }
 
-func updatePodWithOVNPrimaryNetworkIPAMClaimAnnotation(pod *corev1.Pod, ipamClaimName string) {
-	pod.Annotations[config.OVNPrimaryNetworkIPAMClaimAnnotation] = ipamClaimName
+func updatePodWithOVNPrimaryNetworkIPAMClaimAnnotation(patch *annotationsPatch, ipamClaimName string) {
+	patch.set(config.OVNPrimaryNetworkIPAMClaimAnnotation, ipamClaimName)
 }
 
 func ensureIPAMClaimRefAtNetworkSelectionElements(
 	ctx context.Context,
 	cli client.Client,
+	nadConfigs *nads.ConfigCache,
 	vmi *virtv1.VirtualMachineInstance,
 	networkSelectionElements []*v1.NetworkSelectionElement,
+	ipamClaims ipamClaimRequests,
+	warnings *admissionWarnings,
 ) (bool, error) {
 	log := logf.FromContext(ctx)
-	vmiSpecNetworks := vmiSecondaryNetworks(vmi)
+	vmiSpecNetworks := newSecondaryNetworksMatcher(vmi)
 	hasChangedNetworkSelectionElements := false
 	for i, networkSelectionElement := range networkSelectionElements {
 		nadName := fmt.Sprintf("%s/%s", networkSelectionElement.Namespace, networkSelectionElement.Name)
@@ -291,14 +481,79 @@ func ensureIPAMClaimRefAtNetworkSelectionElements(
 		if err := cli.Get(context.Background(), nadKey, &nad); err != nil {
 			if k8serrors.IsNotFound(err) {
 				log.Info("NAD not found, will hang on scheduler", "NAD", nadName)
+				warnings.addNADNotFound(nadName)
 				return false, nil
 			}
 			return false, err
 		}
 
-		pluginConfig, err := config.NewConfig(nad.Spec.Config)
+		pluginConfig, err := nadConfigs.Config(&nad)
 		if err != nil {
-			return false, err
+			return false, invalidNADConfigError(nadName, err)
+		}
+
+		networkName, foundNetworkName := vmiSpecNetworks.match(nadKey, networkSelectionElement)
+		if !foundNetworkName {
+			log.Info(
+				"network name not found",
+				"NAD", nadName,
+				"network", pluginConfig.Name,
+			)
+			warnings.add("no VM network matches the pod attachment to NAD %q, the attachment is not mutated", nadName)
+			continue
+		}
+
+		if iface := vmiNetworkInterface(vmi, networkName); iface != nil && iface.MacAddress != "" {
+			hasChanged, err := ensureMACRequest(networkSelectionElement, iface.MacAddress)
+			if err != nil {
+				return false, err
+			}
+			log.Info(
+				"requesting MAC address",
+				"NAD", nadName,
+				"network", pluginConfig.Name,
+				"MAC", iface.MacAddress,
+			)
+			hasChangedNetworkSelectionElements = hasChangedNetworkSelectionElements || hasChanged
+		}
+
+		ipRequests, err := ips.VmiInterfaceIPRequests(vmi, networkName, pluginConfig)
+		if err != nil {
+			return false, ipRequestsError(err)
+		}
+		if len(ipRequests) > 0 {
+			hasChanged, err := ensureIPRequests(networkSelectionElement, ipRequests)
+			if err != nil {
+				return false, err
+			}
+			if !pluginConfig.AllowPersistentIPs {
+				warnings.addNotPersistedIPRequests(networkName, pluginConfig.Name)
+			}
+			log.Info(
+				"requesting IPs",
+				"NAD", nadName,
+				"network", pluginConfig.Name,
+				"IPs", ipRequests,
+			)
+			hasChangedNetworkSelectionElements = hasChangedNetworkSelectionElements || hasChanged
+		}
+
+		gateways, err := ips.VmiInterfaceGatewayRequests(vmi, networkName, pluginConfig)
+		if err != nil {
+			return false, ipRequestsError(err)
+		}
+		if len(gateways) > 0 {
+			hasChanged, err := ensureGatewayRequest(networkSelectionElement, gateways)
+			if err != nil {
+				return false, err
+			}
+			log.Info(
+				"requesting the default route",
+				"NAD", nadName,
+				"network", pluginConfig.Name,
+				"gateways", gateways,
+			)
+			hasChangedNetworkSelectionElements = hasChangedNetworkSelectionElements || hasChanged
 		}
 
 		if !pluginConfig.AllowPersistentIPs {
@@ -311,39 +566,251 @@ func ensureIPAMClaimRefAtNetworkSelectionElements(
 			"network", pluginConfig.Name,
 		)
 
-		networkName, foundNetworkName := vmiSpecNetworks[nadKey.String()]
-		if !foundNetworkName {
-			log.Info(
-				"network name not found",
-				"NAD", nadName,
-				"network", networkName,
-			)
-			continue
+		ipamClaimName := claims.ComposeKey(vmi.Name, networkName)
+		if networkSelectionElements[i].IPAMClaimReference != ipamClaimName {
+			networkSelectionElements[i].IPAMClaimReference = ipamClaimName
+			hasChangedNetworkSelectionElements = true
 		}
-
-		networkSelectionElements[i].IPAMClaimReference = claims.ComposeKey(vmi.Name, networkName)
+		ipamClaims[networkName] = pluginConfig.Name
 		log.Info(
 			"requesting claim",
 			"NAD", nadName,
 			"network", pluginConfig.Name,
 			"claim", networkSelectionElement.IPAMClaimReference,
 		)
-		hasChangedNetworkSelectionElements = true
 		continue
 	}
 	return hasChangedNetworkSelectionElements, nil
 }
 
-func findPrimaryUDNInterface(
+// ensureIPAMClaimRefAtMultusDefaultNetwork returns the multus default network selection element updated with the
+// VM interface MAC / IP requests and IPAMClaim reference; nil when the element does not need to be changed
+func ensureIPAMClaimRefAtMultusDefaultNetwork(
 	ctx context.Context,
+	cli client.Client,
+	nadConfigs *nads.ConfigCache,
 	vmi *virtv1.VirtualMachineInstance,
-	pluginConfig *config.RelevantConfig,
-) (*virtv1.Interface, error) {
+	pod *corev1.Pod,
+	ipamClaims ipamClaimRequests,
+	warnings *admissionWarnings,
+) (*v1.NetworkSelectionElement, error) {
 	log := logf.FromContext(ctx)
 
-	if !pluginConfig.AllowPersistentIPs {
+	multusDefaultNetwork := vmiMultusDefaultNetwork(vmi)
+	if multusDefaultNetwork == nil {
+		return nil, nil
+	}
+
+	rawDefaultNetwork, hasDefaultNetwork := pod.Annotations[config.MultusDefaultNetAnnotation]
+	if !hasDefaultNetwork {
+		log.Info(
+			"multus default network annotation not found",
+			"network", multusDefaultNetwork.Name,
+		)
+		return nil, nil
+	}
+
+	networkSelectionElement, err := parseMultusDefaultNetwork(rawDefaultNetwork, pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	nadKey := types.NamespacedName{
+		Namespace: networkSelectionElement.Namespace,
+		Name:      networkSelectionElement.Name,
+	}
+	nad := v1.NetworkAttachmentDefinition{}
+	if err := cli.Get(ctx, nadKey, &nad); err != nil {
+		if k8serrors.IsNotFound(err) {
+			log.Info("NAD not found, will hang on scheduler", "NAD", nadKey.String())
+			warnings.addNADNotFound(nadKey.String())
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	pluginConfig, err := nadConfigs.Config(&nad)
+	if err != nil {
+		return nil, invalidNADConfigError(nadKey.String(), err)
+	}
+
+	hasChanged := false
+	if iface := vmiNetworkInterface(vmi, multusDefaultNetwork.Name); iface != nil && iface.MacAddress != "" {
+		hasChangedMAC, err := ensureMACRequest(networkSelectionElement, iface.MacAddress)
+		if err != nil {
+			return nil, err
+		}
+		hasChanged = hasChanged || hasChangedMAC
+	}
+
+	ipRequests, err := ips.VmiInterfaceIPRequests(vmi, multusDefaultNetwork.Name, pluginConfig)
+	if err != nil {
+		return nil, ipRequestsError(err)
+	}
+	if len(ipRequests) > 0 {
+		hasChangedIPs, err := ensureIPRequests(networkSelectionElement, ipRequests)
+		if err != nil {
+			return nil, err
+		}
+		if !pluginConfig.AllowPersistentIPs {
+			warnings.addNotPersistedIPRequests(multusDefaultNetwork.Name, pluginConfig.Name)
+		}
+		hasChanged = hasChanged || hasChangedIPs
+	}
+
+	gateways, err := ips.VmiInterfaceGatewayRequests(vmi, multusDefaultNetwork.Name, pluginConfig)
+	if err != nil {
+		return nil, ipRequestsError(err)
+	}
+	if len(gateways) > 0 {
+		hasChangedGateways, err := ensureGatewayRequest(networkSelectionElement, gateways)
+		if err != nil {
+			return nil, err
+		}
+		hasChanged = hasChanged || hasChangedGateways
+	}
+
+	if pluginConfig.AllowPersistentIPs {
+		ipamClaimName := claims.ComposeKey(vmi.Name, multusDefaultNetwork.Name)
+		if networkSelectionElement.IPAMClaimReference != ipamClaimName {
+			networkSelectionElement.IPAMClaimReference = ipamClaimName
+			hasChanged = true
+		}
+		ipamClaims[multusDefaultNetwork.Name] = pluginConfig.Name
+		log.Info(
+			"requesting claim for the multus default network",
+			"NAD", nadKey.String(),
+			"network", pluginConfig.Name,
+			"claim", networkSelectionElement.IPAMClaimReference,
+		)
+	}
+
+	if !hasChanged {
 		return nil, nil
 	}
+	return networkSelectionElement, nil
+}
+
+// ensureIPRequests sets the requested IPs on the network selection element, refusing to override different IPs; it
+// reports whether the element changed
+func ensureIPRequests(networkSelectionElement *v1.NetworkSelectionElement, ipRequests []string) (bool, error) {
+	if reflect.DeepEqual(networkSelectionElement.IPRequest, ipRequests) {
+		return false, nil
+	}
+	if len(networkSelectionElement.IPRequest) > 0 {
+		return false, ValidationError{
+			Reason: ReasonConflictingNetworkRequests,
+			Message: fmt.Sprintf(
+				"network selection element %s/%s requests IPs %v which conflict with the VM IP requests %v",
+				networkSelectionElement.Namespace,
+				networkSelectionElement.Name,
+				networkSelectionElement.IPRequest,
+				ipRequests,
+			),
+		}
+	}
+	networkSelectionElement.IPRequest = ipRequests
+	return true, nil
+}
+
+// ensureGatewayRequest sets the default route gateways on the network selection element, refusing to override
+// different gateways; it reports whether the element changed
+func ensureGatewayRequest(networkSelectionElement *v1.NetworkSelectionElement, gateways []net.IP) (bool, error) {
+	if isSameGatewayRequest(networkSelectionElement.GatewayRequest, gateways) {
+		return false, nil
+	}
+	if len(networkSelectionElement.GatewayRequest) > 0 {
+		return false, ValidationError{
+			Reason: ReasonConflictingNetworkRequests,
+			Message: fmt.Sprintf(
+				"network selection element %s/%s requests gateways %v which conflict with the VM gateway requests %v",
+				networkSelectionElement.Namespace,
+				networkSelectionElement.Name,
+				networkSelectionElement.GatewayRequest,
+				gateways,
+			),
+		}
+	}
+	networkSelectionElement.GatewayRequest = gateways
+	return true, nil
+}
+
+func isSameGatewayRequest(gateways, otherGateways []net.IP) bool {
+	if len(gateways) != len(otherGateways) {
+		return false
+	}
+	for i := range gateways {
+		if !gateways[i].Equal(otherGateways[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureMACRequest sets the requested MAC on the network selection element, refusing to override a different one;
+// it reports whether the element changed
+func ensureMACRequest(networkSelectionElement *v1.NetworkSelectionElement, macAddress string) (bool, error) {
+	if networkSelectionElement.MacRequest != "" && isSameMAC(networkSelectionElement.MacRequest, macAddress) {
+		return false, nil
+	}
+	if networkSelectionElement.MacRequest != "" {
+		return false, ValidationError{
+			Reason: ReasonConflictingNetworkRequests,
+			Message: fmt.Sprintf(
+				"network selection element %s/%s requests MAC address %q which conflicts with the VM interface MAC address %q",
+				networkSelectionElement.Namespace,
+				networkSelectionElement.Name,
+				networkSelectionElement.MacRequest,
+				macAddress,
+			),
+		}
+	}
+	networkSelectionElement.MacRequest = macAddress
+	return true, nil
+}
+
+// ensureIPAMClaimReference sets the IPAMClaim reference on the network selection element, refusing to override a
+// reference to another claim; it reports whether the element changed
+func ensureIPAMClaimReference(networkSelectionElement *v1.NetworkSelectionElement, ipamClaimName string) (bool, error) {
+	if networkSelectionElement.IPAMClaimReference == ipamClaimName {
+		return false, nil
+	}
+	if networkSelectionElement.IPAMClaimReference != "" {
+		return false, ValidationError{
+			Reason: ReasonConflictingNetworkRequests,
+			Message: fmt.Sprintf(
+				"network selection element %s/%s references IPAMClaim %q instead of the VM IPAMClaim %q",
+				networkSelectionElement.Namespace,
+				networkSelectionElement.Name,
+				networkSelectionElement.IPAMClaimReference,
+				ipamClaimName,
+			),
+		}
+	}
+	networkSelectionElement.IPAMClaimReference = ipamClaimName
+	return true, nil
+}
+
+func isSameMAC(mac, otherMAC string) bool {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return mac == otherMAC
+	}
+	otherHWAddr, err := net.ParseMAC(otherMAC)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(hwAddr, otherHWAddr)
+}
+
+// findPrimaryUDNInterface returns the VMI interface attached to the primary user defined network; nil when the VMI
+// has no pod network
+func findPrimaryUDNInterface(
+	ctx context.Context,
+	vmi *virtv1.VirtualMachineInstance,
+	warnings *admissionWarnings,
+) *virtv1.Interface {
+	log := logf.FromContext(ctx)
 
 	podNetwork := vmiPodNetwork(vmi)
 	if podNetwork == nil {
@@ -352,15 +819,26 @@ func findPrimaryUDNInterface(
 			"vmi",
 			client.ObjectKeyFromObject(vmi),
 		)
-		return nil, nil
+		return nil
 	}
 
-	return vmiNetworkInterface(vmi, *podNetwork), nil
+	iface := vmiNetworkInterface(vmi, podNetwork.Name)
+	if iface == nil {
+		log.Info(
+			"vmi pod network has no matching interface, primary UDN ipam claim will not be requested",
+			"vmi", client.ObjectKeyFromObject(vmi),
+			"network", podNetwork.Name,
+		)
+		warnings.add("VM pod network %q has no matching interface, its primary network requests are not honored",
+			podNetwork.Name)
+	}
+	return iface
 }
 
 func primaryNetworkConfig(
-	cli client.Client,
+	cli client.Reader,
 	ctx context.Context,
+	primaryNetworks *udn.PrimaryNetworkFinder,
 	podNamespace string,
 ) (*config.RelevantConfig, error) {
 	log := logf.FromContext(ctx)
@@ -369,12 +847,12 @@ func primaryNetworkConfig(
 		"namespace", podNamespace,
 	)
 
-	primaryNetworkNAD, err := udn.FindPrimaryNetwork(ctx, cli, podNamespace)
+	pluginConfig, err := primaryNetworks.FindPrimaryNetworkConfig(ctx, cli, podNamespace)
 	if err != nil {
 		return nil, err
 	}
 
-	if primaryNetworkNAD == nil {
+	if pluginConfig == nil {
 		log.V(1).Info(
 			"Did not find primary network config",
 			"namespace", podNamespace,
@@ -382,23 +860,11 @@ func primaryNetworkConfig(
 		return nil, nil
 	}
 
-	log.Info(
-		"Found primary network NAD",
-		"NAD",
-		client.ObjectKeyFromObject(primaryNetworkNAD),
-		"NADs contents",
-		primaryNetworkNAD.Spec.Config,
-	)
-
-	pluginConfig, err := config.NewConfig(primaryNetworkNAD.Spec.Config)
 	log.Info(
 		"plugin config",
 		"namespace", podNamespace,
 		"plugin", pluginConfig,
 	)
-	if err != nil {
-		return nil, err
-	}
 	return pluginConfig, nil
 }
 
@@ -412,9 +878,19 @@ func vmiPodNetwork(vmi *virtv1.VirtualMachineInstance) *virtv1.Network {
 	return nil
 }
 
-func vmiNetworkInterface(vmi *virtv1.VirtualMachineInstance, network virtv1.Network) *virtv1.Interface {
+// returns the KubeVirt VM multus network replacing the cluster default network
+func vmiMultusDefaultNetwork(vmi *virtv1.VirtualMachineInstance) *virtv1.Network {
+	for _, network := range vmi.Spec.Networks {
+		if network.Multus != nil && network.Multus.Default {
+			return &network
+		}
+	}
+	return nil
+}
+
+func vmiNetworkInterface(vmi *virtv1.VirtualMachineInstance, networkName string) *virtv1.Interface {
 	for _, iface := range vmi.Spec.Domain.Devices.Interfaces {
-		if iface.Name == network.Name {
+		if iface.Name == networkName {
 			return &iface
 		}
 	}
@@ -425,6 +901,7 @@ func multusDefaultNetworkAnnotation(
 	namespace string,
 	mac string,
 	ipamClaimName string,
+	gateways []net.IP,
 	ips ...string,
 ) *v1.NetworkSelectionElement {
 	const defaultNetworkName = "default"
@@ -432,6 +909,7 @@ func multusDefaultNetworkAnnotation(
 		Name:               defaultNetworkName,
 		Namespace:          namespace,
 		IPRequest:          ips,
+		GatewayRequest:     gateways,
 		MacRequest:         mac,
 		IPAMClaimReference: ipamClaimName,
 	}
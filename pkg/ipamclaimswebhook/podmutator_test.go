@@ -15,11 +15,15 @@ import (
 	"gomodules.xyz/jsonpatch/v2"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,16 +38,28 @@ import (
 	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
 	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 
+	"github.com/kubevirt/ipam-extensions/pkg/claims"
 	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
 )
 
 type testConfig struct {
 	inputVM                   *virtv1.VirtualMachine
 	inputVMI                  *virtv1.VirtualMachineInstance
+	inputUncachedVMI          *virtv1.VirtualMachineInstance
 	inputNADs                 []*nadv1.NetworkAttachmentDefinition
+	inputIPAMClaims           []*ipamclaimsapi.IPAMClaim
+	inputNamespace            *corev1.Namespace
 	inputPod                  *corev1.Pod
+	vmiResolutionPolicy       VMIResolutionPolicy
+	defaultNetworkRequesters  string
+	requestUserInfo           authenticationv1.UserInfo
+	dryRun                    bool
 	expectedAdmissionResponse admissionv1.AdmissionResponse
 	expectedAdmissionPatches  types.GomegaMatcher
+	expectedEvents            []string
+	expectedIPAMClaims        []ipamclaimsapi.IPAMClaim
 }
 
 func TestController(t *testing.T) {
@@ -96,27 +112,81 @@ var _ = Describe("KubeVirt IPAM launcher pod mutato machine", Serial, func() {
 			initialObjects = append(initialObjects, nad)
 		}
 
+		for _, ipamClaim := range config.inputIPAMClaims {
+			initialObjects = append(initialObjects, ipamClaim)
+		}
+
+		if config.inputNamespace != nil {
+			initialObjects = append(initialObjects, config.inputNamespace)
+		}
+
+		nadConfigs := nads.NewConfigCache()
+		cli := withNADIndexes(fake.NewClientBuilder(), nadConfigs).
+			WithScheme(scheme.Scheme).
+			WithObjects(initialObjects...).
+			Build()
 		ctrlOptions := controllerruntime.Options{
 			Scheme: scheme.Scheme,
 			NewClient: func(_ *rest.Config, _ client.Options) (client.Client, error) {
-				return fake.NewClientBuilder().
-					WithScheme(scheme.Scheme).
-					WithObjects(initialObjects...).
-					Build(), nil
+				return cli, nil
 			},
 		}
 
 		mgr, err := controllerruntime.NewManager(&rest.Config{}, ctrlOptions)
 		Expect(err).NotTo(HaveOccurred())
 
-		ipamClaimsManager := NewIPAMClaimsValet(mgr, WithDefaultNetNADNamespace(namespaceName))
+		// the API server holds the cached objects, along with the objects the informers did not catch up with yet
+		apiServerObjects := initialObjects
+		if config.inputUncachedVMI != nil {
+			apiServerObjects = append(apiServerObjects, config.inputUncachedVMI)
+		}
+		apiReader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(apiServerObjects...).Build()
+
+		recorder := &record.FakeRecorder{Events: make(chan string, 10), IncludeObject: true}
+		valetOpts := []Option{
+			WithDefaultNetNADNamespace(namespaceName),
+			WithAPIReader(apiReader),
+			WithEventRecorder(recorder),
+		}
+		if config.vmiResolutionPolicy != "" {
+			valetOpts = append(valetOpts, WithVMIResolutionPolicy(config.vmiResolutionPolicy))
+		}
+		if config.defaultNetworkRequesters != "" {
+			allowlist, err := ParseIdentityAllowlist(config.defaultNetworkRequesters)
+			Expect(err).NotTo(HaveOccurred())
+			valetOpts = append(valetOpts, WithDefaultNetworkRequesters(allowlist))
+		}
+		ipamClaimsManager := NewIPAMClaimsValet(
+			mgr,
+			nadConfigs,
+			udn.NewPrimaryNetworkFinder(nadConfigs),
+			valetOpts...,
+		)
 
-		result := ipamClaimsManager.Handle(context.Background(), podAdmissionRequest(config.inputPod))
+		request := podAdmissionRequest(config.inputPod)
+		request.UserInfo = config.requestUserInfo
+		request.DryRun = ptr.To(config.dryRun)
+		result := ipamClaimsManager.Handle(context.Background(), request)
 
 		Expect(result.AdmissionResponse).To(Equal(config.expectedAdmissionResponse))
 		if config.expectedAdmissionPatches != nil {
 			Expect(result.Patches).To(config.expectedAdmissionPatches)
 		}
+		close(recorder.Events)
+		var events []string
+		for event := range recorder.Events {
+			events = append(events, event)
+		}
+		Expect(events).To(Equal(config.expectedEvents))
+		if config.expectedIPAMClaims != nil {
+			ipamClaims := &ipamclaimsapi.IPAMClaimList{}
+			Expect(cli.List(context.Background(), ipamClaims)).To(Succeed())
+			for i := range ipamClaims.Items {
+				ipamClaims.Items[i].TypeMeta = metav1.TypeMeta{}
+				ipamClaims.Items[i].ResourceVersion = ""
+			}
+			Expect(ipamClaims.Items).To(ConsistOf(config.expectedIPAMClaims))
+		}
 	},
 		Entry("pod not beloging to a VM and not requesting secondary "+
 			"attachments and no primary user defined network is accepted", testConfig{
@@ -134,10 +204,953 @@ var _ = Describe("KubeVirt IPAM launcher pod mutato machine", Serial, func() {
 				},
 			},
 		}),
-		Entry("vm launcher pod with an attachment to a primary and secondary user "+
-			"defined network with persistent IPs enabled requests an IPAMClaim", testConfig{
+		Entry("vm launcher pod with an attachment to a primary and secondary user "+
+			"defined network with persistent IPs enabled requests an IPAMClaim", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+				dummyPrimaryNetworkNAD(nadName),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
+					Value:     "vm1.podnet",
+				},
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with primary user defined network defined "+
+			"at namespace with persistent IPs enabled requests an IPAMClaim", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNAD(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
+					Value:     "vm1.podnet",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with a MAC address request for primary user defined network defined "+
+			"at namespace with persistent IPs enabled requests an IPAMClaim", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithMACRequest("podnet", "02:03:04:05:06:07")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNAD(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
+					Value:     "vm1.podnet",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\"," +
+						"\"mac\":\"02:03:04:05:06:07\",\"ipam-claim-reference\":\"vm1.podnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with requested MAC and IPs for primary user defined network defined "+
+			"at namespace with persistent IPs enabled requests an IPAMClaim", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(
+				nadName,
+				WithMACRequest("podnet", "02:03:04:05:06:07"),
+				WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200"),
+			),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNAD(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
+					Value:     "vm1.podnet",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\"," +
+						"\"ips\":[\"192.168.1.10/16\",\"fd12:1234::200/64\"]," +
+						"\"mac\":\"02:03:04:05:06:07\",\"ipam-claim-reference\":\"vm1.podnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with requested MAC and IPs for primary user defined network defined "+
+			"at namespace *without* persistent IPs requests the MAC and IPs without an IPAMClaim", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(
+				nadName,
+				WithMACRequest("podnet", "02:03:04:05:06:07"),
+				WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200"),
+			),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNADWithoutPersistentIPs(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+				Warnings: []string{
+					`interface "podnet" requests IPs on network "primarynet" which does not allow persistent IPs, ` +
+						`the IPs are requested without being persisted`,
+				},
+			},
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\"," +
+						"\"ips\":[\"192.168.1.10/16\",\"fd12:1234::200/64\"],\"mac\":\"02:03:04:05:06:07\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with requested IPs and gateways for primary user defined network defined "+
+			"at namespace *without* persistent IPs requests the IPs and the default route", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withAnnotation(config.IPRequestsAnnotation, `{"podnet": {
+				"ips": ["192.168.1.10"], "gateways": ["192.168.0.254"], "defaultRoute": true
+			}}`)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNADWithoutPersistentIPs(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+				Warnings: []string{
+					`interface "podnet" requests IPs on network "primarynet" which does not allow persistent IPs, ` +
+						`the IPs are requested without being persisted`,
+				},
+			},
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\"," +
+						"\"ips\":[\"192.168.1.10/16\"],\"default-route\":[\"192.168.0.254\"]}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod without MAC and IP requests for primary user defined network defined "+
+			"at namespace *without* persistent IPs is not mutated", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNADWithoutPersistentIPs(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+			},
+		}),
+		Entry("vm launcher pod with an attachment to a secondary user defined "+
+			"network with persistent IPs enabled requests an IPAMClaim", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with an attachment to a secondary network enabling persistent IPs in its IPAM block "+
+			"requests an IPAMClaim", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "plugins": [
+					{"type": "bridge", "ipam": {"type": "whereabouts", "range": "10.0.0.0/24", "allowPersistentIPs": true}},
+					{"type": "tuning"}
+				]}`),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with a pod network in a namespace whose primary network may be a NAD with an "+
+			"invalid configuration is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+				dummyNADWithConfig("ns1/brokennet", "{not json}"),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidNADConfig, "",
+				`the primary network of namespace "ns1" cannot be resolved since the configuration of the NADs `+
+					`[brokennet] cannot be parsed`),
+		}),
+		Entry("vm launcher pod *without* a pod network ignores the NADs with an invalid configuration", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withoutPodNetwork()),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+				dummyNADWithConfig("ns1/brokennet", "{not json}"),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod *without* a pod network in a namespace with several primary networks "+
+			"requests an IPAMClaim for its secondary network", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withoutPodNetwork()),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+				dummyPrimaryNetworkNAD(nadName),
+				dummyPrimaryNetworkNAD(nadName + "2"),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with a pod network in a namespace with several primary networks is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+				dummyPrimaryNetworkNAD(nadName),
+				dummyPrimaryNetworkNAD(nadName + "2"),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonAmbiguousPrimaryNetwork, "",
+				"ambiguous primary network: namespace \"ns1\" has several primary network NADs "+
+					"[supadupanet2primary supadupanetprimary]"),
+		}),
+		Entry("vm launcher pod with IP requests for a secondary network with persistent IPs enabled "+
+			"requests the IPs and an IPAMClaim", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("randomnet", "10.10.0.5")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithSubnets(nadName, "10.10.0.0/24"),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value: "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ips\":[\"10.10.0.5/24\"]," +
+						"\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with IP requests for a secondary network *without* persistent IPs "+
+			"requests the IPs", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("randomnet", "10.10.0.5")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "subnets": "10.10.0.0/24"}`),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+				Warnings: []string{
+					`interface "randomnet" requests IPs on network "goodnet" which does not allow persistent IPs, ` +
+						`the IPs are requested without being persisted`,
+				},
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ips\":[\"10.10.0.5/24\"]}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with IP requests for a secondary network with several subnets per family "+
+			"requests the IPs with the prefix length of the subnet holding them", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("randomnet", "10.20.0.5")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "subnets": "10.10.0.0/24,10.20.0.0/16"}`),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+				Warnings: []string{
+					`interface "randomnet" requests IPs on network "goodnet" which does not allow persistent IPs, ` +
+						`the IPs are requested without being persisted`,
+				},
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ips\":[\"10.20.0.5/16\"]}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod requesting the default route on a secondary network requests the gateway "+
+			"of the network subnet", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withAnnotation(config.IPRequestsAnnotation, `{"randomnet": {"defaultRoute": true}}`)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "type": "bridge", "ipam": {"type": "whereabouts", `+
+					`"range": "10.10.0.0/24", "gateway": "10.10.0.254"}}`),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"default-route\":[\"10.10.0.254\"]}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod requesting the default route without gateways on a secondary network whose subnet "+
+			"has no gateway is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withAnnotation(config.IPRequestsAnnotation, `{"randomnet": {"defaultRoute": true}}`)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "subnets": "10.10.0.0/24"}`),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidIPRequests, "",
+				`the subnet 10.10.0.0/24 of network "goodnet" has no gateway, hence the default route requires `+
+					`the gateways to be requested`),
+		}),
+		Entry("vm launcher pod with a gateway request conflicting with the one on its secondary network "+
+			"selection element is denied", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withAnnotation(config.IPRequestsAnnotation,
+				`{"randomnet": {"defaultRoute": true, "gateways": ["10.10.0.254"]}}`,
+			)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "subnets": "10.10.0.0/24"}`),
+			},
+			inputPod: dummyPodForVM(`[{"name":"supadupanet","namespace":"ns1","default-route":["10.10.0.1"]}]`, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonConflictingNetworkRequests, "",
+				"network selection element ns1/supadupanet requests gateways [10.10.0.1] "+
+					"which conflict with the VM gateway requests [10.10.0.254]"),
+		}),
+		Entry("vm launcher pod with IP requests conflicting with the ones on its secondary network "+
+			"selection element is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("randomnet", "10.10.0.5")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithSubnets(nadName, "10.10.0.0/24"),
+			},
+			inputPod: dummyPodForVM(`[{"name":"supadupanet","namespace":"ns1","ips":["10.10.0.6/24"]}]`, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonConflictingNetworkRequests, "",
+				"network selection element ns1/supadupanet requests IPs [10.10.0.6/24] "+
+					"which conflict with the VM IP requests [10.10.0.5/24]"),
+		}),
+		Entry("vm launcher pod with IP requests for a layer3 secondary network is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("randomnet", "10.10.0.5")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName, `{"name": "goodnet", "topology": "layer3", "subnets": "10.10.0.0/16/24"}`),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonUnsupportedIPRequests, "",
+				"IP requests are not supported on the layer3 network \"goodnet\": its subnets are split "+
+					"into per node host subnets, hence the VM IPs would depend on the node the VM runs on"),
+		}),
+		Entry("vm launcher pod with IP requests for a layer3 primary network is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithConfig(nadName+"primary", `{"name": "primarynet", "role": "primary", "topology": "layer3", `+
+					`"allowPersistentIPs": true, "subnets": "192.168.0.0/16/24"}`),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonUnsupportedIPRequests, "",
+				"IP requests are not supported on the layer3 network \"primarynet\": its subnets are split "+
+					"into per node host subnets, hence the VM IPs would depend on the node the VM runs on"),
+		}),
+		Entry("vm launcher pod with a MAC address request for a secondary network with persistent IPs enabled "+
+			"requests the MAC address and an IPAMClaim", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(
+				nadName,
+				withInterface("randomnet"),
+				WithMACRequest("randomnet", "02:03:04:05:06:07"),
+			),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value: "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"mac\":\"02:03:04:05:06:07\"," +
+						"\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with a MAC address request conflicting with the one on its secondary network "+
+			"selection element is denied", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(
+				nadName,
+				withInterface("randomnet"),
+				WithMACRequest("randomnet", "02:03:04:05:06:07"),
+			),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(`[{"name":"supadupanet","namespace":"ns1","mac":"02:00:00:00:00:01"}]`, vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonConflictingNetworkRequests, "",
+				"network selection element ns1/supadupanet requests MAC address \"02:00:00:00:00:01\" "+
+					"which conflicts with the VM interface MAC address \"02:03:04:05:06:07\""),
+		}),
+		Entry("vm launcher pod whose secondary network selection element already requests the VM interface MAC "+
+			"address is not mutated", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(
+				nadName,
+				withInterface("randomnet"),
+				WithMACRequest("randomnet", "02:03:04:05:06:07"),
+			),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithoutPersistentIPs(nadName),
+			},
+			inputPod: dummyPodForVM(`[{"name":"supadupanet","namespace":"ns1","mac":"02:03:04:05:06:07"}]`, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+			},
+		}),
+		Entry("vm launcher pod with two attachments to the same secondary network with persistent IPs enabled "+
+			"requests an IPAMClaim per VM network", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withSecondaryNetwork("bondnet", nadName)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(
+				`[{"name":"supadupanet","namespace":"ns1"},{"name":"supadupanet","namespace":"ns1"}]`,
+				vmName,
+			),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value: "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}," +
+						"{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.bondnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with two attachments to the same secondary network with persistent IPs enabled "+
+			"matches the VM networks by pod interface name", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withSecondaryNetwork("bondnet", nadName)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(
+				fmt.Sprintf(`[{"name":"supadupanet","namespace":"ns1","interface":%q},`+
+					`{"name":"supadupanet","namespace":"ns1","interface":%q}]`,
+					hashedPodIfaceName("bondnet"), hashedPodIfaceName("randomnet"),
+				),
+				vmName,
+			),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+					Value: fmt.Sprintf("[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"interface\":%q,"+
+						"\"ipam-claim-reference\":\"vm1.bondnet\"},"+
+						"{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"interface\":%q,"+
+						"\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+						hashedPodIfaceName("bondnet"), hashedPodIfaceName("randomnet"),
+					),
+				},
+			}),
+		}),
+		Entry("vm launcher pod whose multus default network has persistent IPs enabled requests an IPAMClaim "+
+			"on the multus default network selection element", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withMultusDefaultNetwork("defaultnet", nadName)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: nadName}),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.defaultnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod with requested MAC and IPs for its multus default network with persistent IPs "+
+			"enabled requests them along with an IPAMClaim", testConfig{
+			inputVM: dummyVM(nadName),
+			inputVMI: dummyVMI(
+				nadName,
+				withMultusDefaultNetwork("defaultnet", nadName),
+				WithMACRequest("defaultnet", "02:03:04:05:06:07"),
+				WithIPRequests("defaultnet", "10.10.0.5"),
+			),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithSubnets(nadName, "10.10.0.0/24"),
+			},
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: nadName}),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed:   true,
+				PatchType: &patchType,
+			},
+			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "replace",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value: "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ips\":[\"10.10.0.5/24\"]," +
+						"\"mac\":\"02:03:04:05:06:07\",\"ipam-claim-reference\":\"vm1.defaultnet\"}]",
+				},
+			}),
+		}),
+		Entry("vm launcher pod whose multus default network has persistent IPs disabled is accepted", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withMultusDefaultNetwork("defaultnet", nadName)),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithoutPersistentIPs(nadName),
+			},
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: nadName}),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+			},
+		}),
+		Entry("vm launcher pod with an attachment to a network *without* persistentIPs is accepted", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNADWithoutPersistentIPs(nadName),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+			},
+		}),
+		Entry("pod not belonging to a VM with an attachment to a network with persistent IPs enabled is accepted", testConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPod(nadName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "not a VM",
+					Code:    http.StatusOK,
+				},
+			},
+		}),
+		Entry("pod requesting an attachment via a NAD with an invalid configuration throws a BAD REQUEST", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM("{not json}", vmName),
+			expectedAdmissionResponse: erroredResponse(http.StatusBadRequest, ReasonMalformedRequest,
+				"failed to parse pod network selection elements"),
+		}),
+		Entry("pod requesting an attachment via a NAD which does not exist is accepted with a warning", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+				Warnings: []string{`NAD "ns1/supadupanet" not found, the pod network attachments are not mutated`},
+			},
+		}),
+		Entry("pod requesting an attachment to a NAD no VM network refers to is accepted with a warning", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+				dummyNAD("ns1/othernet"),
+			},
+			inputPod: dummyPodForVM(`[{"name":"othernet","namespace":"ns1"}]`, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+				Warnings: []string{`no VM network matches the pod attachment to NAD "ns1/othernet", ` +
+					`the attachment is not mutated`},
+			},
+		}),
+		Entry("vm launcher pod whose pod network has no matching interface is accepted with a warning", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withoutInterface("podnet")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNAD(nadName),
+			},
+			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+				Warnings: []string{`VM pod network "podnet" has no matching interface, ` +
+					`its primary network requests are not honored`},
+			},
+		}),
+		Entry("pod belonging to VM not requesting secondary attachments nor primary user-defined network "+
+			"is accepted", testConfig{
+			inputPod: dummyPodForVM("", vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "no mutation required",
+					Code:    http.StatusOK,
+				},
+			},
+		}),
+		Entry("pod belonging to VM not requesting secondary attachments in a namespace whose primary network "+
+			"cannot be resolved since a NAD has an invalid configuration is denied", testConfig{
+			inputVM:   dummyVM(nadName),
+			inputVMI:  dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{dummyNADWithConfig("ns1/brokennet", "{not json}")},
+			inputPod:  dummyPodForVM("", vmName),
+			expectedAdmissionResponse: deniedResponse(ReasonInvalidNADConfig, "",
+				`the primary network of namespace "ns1" cannot be resolved since the configuration of the NADs `+
+					`[brokennet] cannot be parsed`),
+		}),
+		Entry("launcher pod whose VMI is not found throws a server error", testConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: erroredResponse(http.StatusInternalServerError, ReasonVMINotFound,
+				`failed to access the VMI running in pod "ns1/pod1": `+
+					`virtualmachineinstances.kubevirt.io "vm1" not found`),
+		}),
+		Entry("launcher pod with a generated name whose VMI is not found throws a server error", testConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: withGeneratedName(dummyPodForVM(nadName, vmName)),
+			expectedAdmissionResponse: erroredResponse(http.StatusInternalServerError, ReasonVMINotFound,
+				`failed to access the VMI running in pod "ns1/virt-launcher-vm1-": `+
+					`virtualmachineinstances.kubevirt.io "vm1" not found`),
+		}),
+		Entry("launcher pod whose VMI is missing from the cache is mutated after reading the VMI from the API server",
+			testConfig{
+				inputVM:          dummyVM(nadName),
+				inputUncachedVMI: dummyVMI(nadName),
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNAD(nadName),
+				},
+				inputPod: dummyPodForVM(nadName, vmName),
+				expectedAdmissionResponse: admissionv1.AdmissionResponse{
+					Allowed:   true,
+					PatchType: &patchType,
+				},
+				expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+					{
+						Operation: "replace",
+						Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
+						Value:     `[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm1.randomnet"}]`,
+					},
+				}),
+			}),
+		Entry("launcher pod whose VMI is not found is admitted without mutation by the best-effort policy",
+			testConfig{
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNAD(nadName),
+				},
+				inputPod:            dummyPodForVM(nadName, vmName),
+				vmiResolutionPolicy: VMIResolutionPolicyBestEffort,
+				expectedAdmissionResponse: admissionv1.AdmissionResponse{
+					Allowed: true,
+					Result: &metav1.Status{
+						Message: "VMI not resolved, the pod is admitted without mutation",
+						Code:    http.StatusOK,
+					},
+					Warnings: []string{vmiNotResolvedMessage},
+				},
+				expectedEvents: []string{vmiNotResolvedEvent},
+			}),
+		Entry("launcher pod with a generated name whose VMI is not found is admitted without mutation by the "+
+			"best-effort policy, reporting it on the VMI", testConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod:            withGeneratedName(dummyPodForVM(nadName, vmName)),
+			vmiResolutionPolicy: VMIResolutionPolicyBestEffort,
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "VMI not resolved, the pod is admitted without mutation",
+					Code:    http.StatusOK,
+				},
+				Warnings: []string{`VMI "ns1/vm1" could not be resolved, the pod is admitted without requesting ` +
+					`persistent IPs: failed to access the VMI running in pod "ns1/virt-launcher-vm1-": ` +
+					`virtualmachineinstances.kubevirt.io "vm1" not found`},
+			},
+			expectedEvents: []string{`Warning VMIUnresolved VMI "ns1/vm1" could not be resolved, the pod ` +
+				`"ns1/virt-launcher-vm1-" is admitted without requesting persistent IPs: failed to access the VMI ` +
+				`running in pod "ns1/virt-launcher-vm1-": virtualmachineinstances.kubevirt.io "vm1" not found ` +
+				`involvedObject{kind=VirtualMachineInstance,apiVersion=kubevirt.io/v1}`},
+		}),
+		Entry("launcher pod whose VMI is not found is admitted without mutation when its namespace "+
+			"overrides the policy", testConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputNamespace: namespaceWithVMIResolutionPolicy("ns1", VMIResolutionPolicyBestEffort),
+			inputPod:       dummyPodForVM(nadName, vmName),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "VMI not resolved, the pod is admitted without mutation",
+					Code:    http.StatusOK,
+				},
+				Warnings: []string{vmiNotResolvedMessage},
+			},
+			expectedEvents: []string{vmiNotResolvedEvent},
+		}),
+		Entry("launcher pod whose VMI is not found throws a server error when its namespace overrides the "+
+			"best-effort policy", testConfig{
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputNamespace:      namespaceWithVMIResolutionPolicy("ns1", VMIResolutionPolicyStrict),
+			inputPod:            dummyPodForVM(nadName, vmName),
+			vmiResolutionPolicy: VMIResolutionPolicyBestEffort,
+			expectedAdmissionResponse: erroredResponse(http.StatusInternalServerError, ReasonVMINotFound,
+				`failed to access the VMI running in pod "ns1/pod1": `+
+					`virtualmachineinstances.kubevirt.io "vm1" not found`),
+		}),
+		Entry("pod carrying the VM annotation without being controlled by the VMI is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: pod(nadName, map[string]string{"kubevirt.io/domain": vmName}),
+			expectedAdmissionResponse: deniedResponse(ReasonNotLauncherPod, "metadata.ownerReferences",
+				`pod "ns1/pod1" is not the virt-launcher pod of VMI "ns1/vm1": it is not controlled by the VMI`),
+		}),
+		Entry("pod with a generated name carrying the VM annotation without being controlled by the VMI is denied",
+			testConfig{
+				inputVM:  dummyVM(nadName),
+				inputVMI: dummyVMI(nadName),
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNAD(nadName),
+				},
+				inputPod: withGeneratedName(pod(nadName, map[string]string{"kubevirt.io/domain": vmName})),
+				expectedAdmissionResponse: deniedResponse(ReasonNotLauncherPod, "metadata.ownerReferences",
+					`pod "ns1/virt-launcher-vm1-" is not the virt-launcher pod of VMI "ns1/vm1": `+
+						`it is not controlled by the VMI`),
+			}),
+		Entry("pod controlled by another VMI is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: launcherPod(pod(nadName, map[string]string{"kubevirt.io/domain": vmName}), vmName, "another-uid"),
+			expectedAdmissionResponse: deniedResponse(ReasonNotLauncherPod, "metadata.ownerReferences",
+				`pod "ns1/pod1" is not the virt-launcher pod of VMI "ns1/vm1": it is not controlled by the VMI`),
+		}),
+		Entry("pod controlled by the VMI without the virt-launcher labels is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: withoutLabels(dummyPodForVM(nadName, vmName)),
+			expectedAdmissionResponse: deniedResponse(ReasonNotLauncherPod, "metadata.labels",
+				`pod "ns1/pod1" is not the virt-launcher pod of VMI "ns1/vm1": `+
+					`its "kubevirt.io" and "kubevirt.io/created-by" labels do not match`),
+		}),
+		Entry("vm launcher pod referencing an IPAMClaim of another VM is denied", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputPod: dummyPodForVM(
+				`[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm2.randomnet"}]`,
+				vmName,
+			),
+			expectedAdmissionResponse: deniedResponse(ReasonIPAMClaimNotOwned, "",
+				`pod "ns1/pod1" references IPAMClaim "vm2.randomnet" which is not owned by VM "vm1"`),
+		}),
+		Entry("vm launcher pod referencing an IPAMClaim held by another VM under its own claim name is denied",
+			testConfig{
+				inputVM:  dummyVM(nadName),
+				inputVMI: dummyVMI(nadName),
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNAD(nadName),
+				},
+				inputIPAMClaims: []*ipamclaimsapi.IPAMClaim{
+					ipamClaimOwnedBy("vm1.randomnet", "goodnet", "vm2", "vm2-uid"),
+				},
+				inputPod: dummyPodForVM(
+					`[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm1.randomnet"}]`,
+					vmName,
+				),
+				expectedAdmissionResponse: deniedResponse(ReasonIPAMClaimNotOwned, "",
+					`pod "ns1/pod1" references IPAMClaim "vm1.randomnet" which is not owned by VM "vm1"`),
+			}),
+		Entry("vm launcher pod referencing an IPAMClaim leaked by a former VM with the same name is denied",
+			testConfig{
+				inputVM:  dummyVM(nadName),
+				inputVMI: dummyVMI(nadName, withOwningVM()),
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNAD(nadName),
+				},
+				inputIPAMClaims: []*ipamclaimsapi.IPAMClaim{
+					ipamClaimOwnedBy("vm1.randomnet", "goodnet", vmName, "former-vm1-uid"),
+				},
+				inputPod: dummyPodForVM(
+					`[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm1.randomnet"}]`,
+					vmName,
+				),
+				expectedAdmissionResponse: deniedResponse(ReasonIPAMClaimNotOwned, "",
+					`pod "ns1/pod1" references IPAMClaim "vm1.randomnet" which is not owned by VM "vm1"`),
+			}),
+		Entry("vm launcher pod referencing an IPAMClaim of its VM is accepted", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, withOwningVM()),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			inputIPAMClaims: []*ipamclaimsapi.IPAMClaim{
+				ipamClaimOwnedBy("vm1.randomnet", "goodnet", vmName, dummyVMUID),
+			},
+			inputPod: dummyPodForVM(
+				`[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm1.randomnet"}]`,
+				vmName,
+			),
+			expectedAdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "carry on",
+					Code:    http.StatusOK,
+				},
+			},
+			expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{
+				*ipamClaimOwnedBy("vm1.randomnet", "goodnet", vmName, dummyVMUID),
+			},
+		}),
+		Entry("vm launcher pod requesting IPAMClaims not created yet creates them", testConfig{
 			inputVM:  dummyVM(nadName),
-			inputVMI: dummyVMI(nadName),
+			inputVMI: dummyVMI(nadName, withOwningVM()),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
 				dummyNAD(nadName),
 				dummyPrimaryNetworkNAD(nadName),
@@ -147,77 +1160,83 @@ var _ = Describe("KubeVirt IPAM launcher pod mutato machine", Serial, func() {
 				Allowed:   true,
 				PatchType: &patchType,
 			},
-			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
-				{
-					Operation: "add",
-					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
-					Value:     "vm1.podnet",
-				},
-				{
-					Operation: "replace",
-					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
-					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
-				},
-			}),
+			expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{
+				*ipamClaimOwnedBy("vm1.podnet", "primarynet", vmName, dummyVMUID),
+				*ipamClaimOwnedBy("vm1.randomnet", "goodnet", vmName, dummyVMUID),
+			},
 		}),
-		Entry("vm launcher pod with primary user defined network defined "+
-			"at namespace with persistent IPs enabled requests an IPAMClaim", testConfig{
+		Entry("vm launcher pod dry-run request does not create the IPAMClaims", testConfig{
 			inputVM:  dummyVM(nadName),
-			inputVMI: dummyVMI(nadName),
+			inputVMI: dummyVMI(nadName, withOwningVM()),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
 				dummyPrimaryNetworkNAD(nadName),
 			},
-			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			inputPod: dummyPodForVM(nadName, vmName),
+			dryRun:   true,
 			expectedAdmissionResponse: admissionv1.AdmissionResponse{
 				Allowed:   true,
 				PatchType: &patchType,
 			},
-			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
-				{
-					Operation: "add",
-					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
-					Value:     "vm1.podnet",
+			expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{},
+		}),
+		Entry("vm launcher pod requesting an IPAMClaim leaked by a former VM with the same name is denied",
+			testConfig{
+				inputVM:  dummyVM(nadName),
+				inputVMI: dummyVMI(nadName, withOwningVM()),
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNAD(nadName),
+				},
+				inputIPAMClaims: []*ipamclaimsapi.IPAMClaim{
+					ipamClaimOwnedBy("vm1.randomnet", "goodnet", vmName, "former-vm1-uid"),
+				},
+				inputPod: dummyPodForVM(nadName, vmName),
+				expectedAdmissionResponse: deniedResponse(ReasonIPAMClaimNotOwned, "",
+					`IPAMClaim "vm1.randomnet" already exists and is not owned by VirtualMachine "vm1"`),
+				expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{
+					*ipamClaimOwnedBy("vm1.randomnet", "goodnet", vmName, "former-vm1-uid"),
 				},
 			}),
-		}),
-		Entry("vm launcher pod with a MAC address request for primary user defined network defined "+
-			"at namespace with persistent IPs enabled requests an IPAMClaim", testConfig{
+		Entry("launcher pod with existing default-network multus annotation is denied on creation", testConfig{
 			inputVM:  dummyVM(nadName),
-			inputVMI: dummyVMI(nadName, WithMACRequest("podnet", "02:03:04:05:06:07")),
+			inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200")),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
 				dummyPrimaryNetworkNAD(nadName),
 			},
-			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed:   true,
-				PatchType: &patchType,
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: "[{\"name\":\"not-podnet\"}]"}),
+			expectedAdmissionResponse: deniedResponse(ReasonDefaultNetworkNotAllowed, multusDefaultNetworkAnnotationField,
+				"multus default network annotation \"v1.multus-cni.io/default-network\" is not allowed on pod creation"),
+		}),
+		Entry("launcher pod with existing default-network multus annotation is denied on creation when the "+
+			"requester is not allowed to set it", testConfig{
+			inputVM:  dummyVM(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyPrimaryNetworkNAD(nadName),
 			},
-			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
-				{
-					Operation: "add",
-					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
-					Value:     "vm1.podnet",
-				},
-				{
-					Operation: "add",
-					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
-					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\"," +
-						"\"mac\":\"02:03:04:05:06:07\",\"ipam-claim-reference\":\"vm1.podnet\"}]",
-				},
-			}),
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: trustedDefaultNetwork}),
+			defaultNetworkRequesters: trustedRequesters,
+			requestUserInfo:          authenticationv1.UserInfo{Username: "system:serviceaccount:kubevirt:virt-handler"},
+			expectedAdmissionResponse: deniedResponse(ReasonDefaultNetworkNotAllowed, multusDefaultNetworkAnnotationField,
+				"multus default network annotation \"v1.multus-cni.io/default-network\" is not allowed on pod creation"),
 		}),
-		Entry("vm launcher pod with requested MAC and IPs for primary user defined network defined "+
-			"at namespace with persistent IPs enabled requests an IPAMClaim", testConfig{
+		Entry("launcher pod with default-network multus annotation set by an allowed service account gets "+
+			"the annotation merged with the VM primary network requests", testConfig{
 			inputVM: dummyVM(nadName),
 			inputVMI: dummyVMI(
 				nadName,
 				WithMACRequest("podnet", "02:03:04:05:06:07"),
-				WithIPRequests("podnet", "192.168.1.10", "fd20:1234::200"),
+				WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200"),
 			),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
 				dummyPrimaryNetworkNAD(nadName),
 			},
-			inputPod: dummyPodForVM("" /*without network selection element*/, vmName),
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: trustedDefaultNetwork}),
+			defaultNetworkRequesters: trustedRequesters,
+			requestUserInfo:          authenticationv1.UserInfo{Username: "system:serviceaccount:kubevirt:migrator"},
 			expectedAdmissionResponse: admissionv1.AdmissionResponse{
 				Allowed:   true,
 				PatchType: &patchType,
@@ -229,191 +1248,206 @@ var _ = Describe("KubeVirt IPAM launcher pod mutato machine", Serial, func() {
 					Value:     "vm1.podnet",
 				},
 				{
-					Operation: "add",
+					Operation: "replace",
 					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
 					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\"," +
-						"\"ips\":[\"192.168.1.10/16\",\"fd20:1234::200/64\"]," +
-						"\"mac\":\"02:03:04:05:06:07\",\"ipam-claim-reference\":\"vm1.podnet\"}]",
+						"\"ips\":[\"192.168.1.10/16\",\"fd12:1234::200/64\"],\"mac\":\"02:03:04:05:06:07\"," +
+						"\"interface\":\"eth0\",\"ipam-claim-reference\":\"vm1.podnet\"}]",
 				},
 			}),
 		}),
-		Entry("vm launcher pod with an attachment to a secondary user defined "+
-			"network with persistent IPs enabled requests an IPAMClaim", testConfig{
+		Entry("launcher pod with default-network multus annotation set by a member of an allowed group gets "+
+			"the IPAMClaim reference merged", testConfig{
 			inputVM:  dummyVM(nadName),
 			inputVMI: dummyVMI(nadName),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
-				dummyNAD(nadName),
+				dummyPrimaryNetworkNAD(nadName),
+			},
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: trustedDefaultNetwork}),
+			defaultNetworkRequesters: trustedRequesters,
+			requestUserInfo: authenticationv1.UserInfo{
+				Username: "jdoe",
+				Groups:   []string{"system:authenticated", "network-admins"},
 			},
-			inputPod: dummyPodForVM(nadName, vmName),
 			expectedAdmissionResponse: admissionv1.AdmissionResponse{
 				Allowed:   true,
 				PatchType: &patchType,
 			},
-			expectedAdmissionPatches: Equal([]jsonpatch.JsonPatchOperation{
+			expectedAdmissionPatches: ConsistOf([]jsonpatch.JsonPatchOperation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/k8s.ovn.org~1primary-udn-ipamclaim",
+					Value:     "vm1.podnet",
+				},
 				{
 					Operation: "replace",
-					Path:      "/metadata/annotations/k8s.v1.cni.cncf.io~1networks",
-					Value:     "[{\"name\":\"supadupanet\",\"namespace\":\"ns1\",\"ipam-claim-reference\":\"vm1.randomnet\"}]",
+					Path:      "/metadata/annotations/v1.multus-cni.io~1default-network",
+					Value: "[{\"name\":\"default\",\"namespace\":\"randomNS\",\"interface\":\"eth0\"," +
+						"\"ipam-claim-reference\":\"vm1.podnet\"}]",
 				},
 			}),
 		}),
-		Entry("vm launcher pod with an attachment to a network *without* persistentIPs is accepted", testConfig{
-			inputVM:  dummyVM(nadName),
-			inputVMI: dummyVMI(nadName),
-			inputNADs: []*nadv1.NetworkAttachmentDefinition{
-				dummyNADWithoutPersistentIPs(nadName),
-			},
-			inputPod: dummyPodForVM(nadName, vmName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: true,
-				Result: &metav1.Status{
-					Message: "carry on",
-					Code:    http.StatusOK,
-				},
-			},
-		}),
-		Entry("pod not belonging to a VM with an attachment to a network with persistent IPs enabled is accepted", testConfig{
-			inputNADs: []*nadv1.NetworkAttachmentDefinition{
-				dummyNAD(nadName),
-			},
-			inputPod: dummyPod(nadName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: true,
-				Result: &metav1.Status{
-					Message: "not a VM",
-					Code:    http.StatusOK,
-				},
-			},
-		}),
-		Entry("pod requesting an attachment via a NAD with an invalid configuration throws a BAD REQUEST", testConfig{
+		Entry("launcher pod with default-network multus annotation set by an allowed requester is denied when "+
+			"it conflicts with the VM requests", testConfig{
 			inputVM:  dummyVM(nadName),
-			inputVMI: dummyVMI(nadName),
+			inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10")),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
-				dummyNAD(nadName),
-			},
-			inputPod: dummyPodForVM("{not json}", vmName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: "failed to parse pod network selection elements",
-					Code:    http.StatusBadRequest,
-				},
+				dummyPrimaryNetworkNAD(nadName),
 			},
+			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
+				map[string]string{
+					config.MultusDefaultNetAnnotation: `[{"name":"default","namespace":"randomNS","ips":["192.168.1.20/16"]}]`,
+				}),
+			defaultNetworkRequesters: trustedRequesters,
+			requestUserInfo:          authenticationv1.UserInfo{Username: "migration-controller"},
+			expectedAdmissionResponse: deniedResponse(ReasonConflictingNetworkRequests, "",
+				"network selection element randomNS/default requests IPs [192.168.1.20/16] which conflict with "+
+					"the VM IP requests [192.168.1.10/16]"),
 		}),
-		Entry("pod requesting an attachment via a NAD with an invalid configuration throws a BAD REQUEST", testConfig{
+		Entry("launcher pod with default-network multus annotation set by an allowed requester is denied when "+
+			"it requests another network", testConfig{
 			inputVM:  dummyVM(nadName),
 			inputVMI: dummyVMI(nadName),
-			inputPod: dummyPodForVM(nadName, vmName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: true,
-				Result: &metav1.Status{
-					Message: "carry on",
-					Code:    http.StatusOK,
-				},
-			},
-		}),
-		Entry("pod belonging to VM not requesting secondary attachments nor primary user-defined network "+
-			"is accepted", testConfig{
-			inputPod: dummyPodForVM("", vmName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: true,
-				Result: &metav1.Status{
-					Message: "no mutation required",
-					Code:    http.StatusOK,
-				},
-			},
-		}),
-		Entry("launcher pod whose VMI is not found throws a server error", testConfig{
-			inputNADs: []*nadv1.NetworkAttachmentDefinition{
-				dummyNAD(nadName),
-			},
-			inputPod: dummyPodForVM(nadName, vmName),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: `failed to access the VMI running in pod "ns1/pod1": ` +
-						`virtualmachineinstances.kubevirt.io "vm1" not found`,
-					Code: http.StatusInternalServerError,
-				},
-			},
-		}),
-		Entry("launcher pod with existing default-network multus annotation is denied on creation", testConfig{
-			inputVM:  dummyVM(nadName),
-			inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd20:1234::200")),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
 				dummyPrimaryNetworkNAD(nadName),
 			},
 			inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
-				map[string]string{config.MultusDefaultNetAnnotation: "[{\"name\":\"not-podnet\"}]"}),
-			expectedAdmissionResponse: admissionv1.AdmissionResponse{
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: "multus default network annotation \"v1.multus-cni.io/default-network\" is not allowed on pod creation",
-					Reason:  metav1.StatusReasonForbidden,
-					Code:    http.StatusForbidden,
-				},
-			},
+				map[string]string{config.MultusDefaultNetAnnotation: "[{\"name\":\"podnet\"}]"}),
+			defaultNetworkRequesters: trustedRequesters,
+			requestUserInfo:          authenticationv1.UserInfo{Username: "migration-controller"},
+			expectedAdmissionResponse: deniedResponse(ReasonConflictingNetworkRequests,
+				multusDefaultNetworkAnnotationField,
+				"multus default network annotation requests network ns1/podnet instead of the primary network "+
+					"randomNS/default"),
 		}),
 		Entry("launcher pod with existing default-network multus annotation is denied on creation "+
 			"even if annotation value is correct",
 			testConfig{
 				inputVM:  dummyVM(nadName),
-				inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd20:1234::200")),
+				inputVMI: dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd12:1234::200")),
 				inputNADs: []*nadv1.NetworkAttachmentDefinition{
 					dummyPrimaryNetworkNAD(nadName),
 				},
 				inputPod: dummyPodForVMWithAnnotation("" /*without network selection element*/, vmName,
 					map[string]string{config.MultusDefaultNetAnnotation: "[{\"name\":\"podnet\"}]"}),
-				expectedAdmissionResponse: admissionv1.AdmissionResponse{
-					Allowed: false,
-					Result: &metav1.Status{
-						Message: "multus default network annotation \"v1.multus-cni.io/default-network\" is not allowed on pod creation",
-						Reason:  metav1.StatusReasonForbidden,
-						Code:    http.StatusForbidden,
-					},
-				},
+				expectedAdmissionResponse: deniedResponse(ReasonDefaultNetworkNotAllowed, multusDefaultNetworkAnnotationField,
+					"multus default network annotation \"v1.multus-cni.io/default-network\" is not allowed on pod creation"),
 			}),
 	)
 
-	It("should allow UPDATE operations even with multus default network annotation", func() {
-		var initialObjects []client.Object
-
-		vm := dummyVM(nadName)
-		vmi := dummyVMI(nadName, WithIPRequests("podnet", "192.168.1.10", "fd20:1234::200"))
-		nad := dummyPrimaryNetworkNAD(nadName)
-		pod := dummyPodForVMWithAnnotation("", vmName,
-			map[string]string{config.MultusDefaultNetAnnotation: "[{\"name\":\"podnet\"}]"})
-
-		initialObjects = append(initialObjects, vm, vmi, nad)
-
+	DescribeTable("validates pod update requests not to change the network requests", func(
+		oldPod *corev1.Pod,
+		newPod *corev1.Pod,
+		expectedAdmissionResponse admissionv1.AdmissionResponse,
+	) {
+		nadConfigs := nads.NewConfigCache()
 		ctrlOptions := controllerruntime.Options{
 			Scheme: scheme.Scheme,
 			NewClient: func(_ *rest.Config, _ client.Options) (client.Client, error) {
-				return fake.NewClientBuilder().
-					WithScheme(scheme.Scheme).
-					WithObjects(initialObjects...).
-					Build(), nil
+				return withNADIndexes(fake.NewClientBuilder(), nadConfigs).WithScheme(scheme.Scheme).Build(), nil
 			},
 		}
 
 		mgr, err := controllerruntime.NewManager(&rest.Config{}, ctrlOptions)
 		Expect(err).NotTo(HaveOccurred())
 
-		ipamClaimsManager := NewIPAMClaimsValet(mgr, WithDefaultNetNADNamespace(namespaceName))
+		ipamClaimsManager := NewIPAMClaimsValet(
+			mgr,
+			nadConfigs,
+			udn.NewPrimaryNetworkFinder(nadConfigs),
+			WithDefaultNetNADNamespace(namespaceName),
+		)
 
-		updateRequest := podAdmissionRequestWithOperation(pod, admissionv1.Update)
-		result := ipamClaimsManager.Handle(context.Background(), updateRequest)
+		result := ipamClaimsManager.Handle(context.Background(), podUpdateAdmissionRequest(oldPod, newPod))
 
-		Expect(result.AdmissionResponse.Allowed).To(BeTrue())
-		Expect(result.PatchType).To(Equal(&patchType))
-	})
+		Expect(result.AdmissionResponse).To(Equal(expectedAdmissionResponse))
+		Expect(result.Patches).To(BeEmpty())
+	},
+		Entry("update keeping the network requests is allowed without mutation",
+			dummyPodForVMWithAnnotation(mutatedNetworks, vmName, map[string]string{
+				config.MultusDefaultNetAnnotation:           mutatedDefaultNetwork,
+				config.OVNPrimaryNetworkIPAMClaimAnnotation: "vm1.podnet",
+			}),
+			dummyPodForVMWithAnnotation(mutatedNetworks, vmName, map[string]string{
+				config.MultusDefaultNetAnnotation:              mutatedDefaultNetwork,
+				config.OVNPrimaryNetworkIPAMClaimAnnotation:    "vm1.podnet",
+				"kubevirt.io/migration-target-start-timestamp": "1700000000000",
+			}),
+			admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "network requests not changed",
+					Code:    http.StatusOK,
+				},
+			},
+		),
+		Entry("update changing the IPAMClaim references is denied",
+			dummyPodForVM(mutatedNetworks, vmName),
+			dummyPodForVM(`[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm2.randomnet"}]`, vmName),
+			deniedResponse(ReasonImmutableNetworkRequests, "metadata.annotations[k8s.v1.cni.cncf.io/networks]",
+				`pod "ns1/pod1" network selection elements IPAMClaim references cannot change after the pod creation`),
+		),
+		Entry("update changing the network selection elements is denied",
+			dummyPodForVM(mutatedNetworks, vmName),
+			dummyPodForVM(mutatedNetworks[:len(mutatedNetworks)-1]+`,{"name":"othernet","namespace":"ns1"}]`, vmName),
+			deniedResponse(ReasonImmutableNetworkRequests, "metadata.annotations[k8s.v1.cni.cncf.io/networks]",
+				`pod "ns1/pod1" annotation "k8s.v1.cni.cncf.io/networks" cannot change after the pod creation`),
+		),
+		Entry("update changing the network selection elements of a pod with a generated name is denied",
+			withGeneratedName(dummyPodForVM(mutatedNetworks, vmName)),
+			withGeneratedName(dummyPodForVM(`[{"name":"othernet","namespace":"ns1"}]`, vmName)),
+			deniedResponse(ReasonImmutableNetworkRequests, "metadata.annotations[k8s.v1.cni.cncf.io/networks]",
+				`pod "ns1/virt-launcher-vm1-" network selection elements IPAMClaim references cannot change after `+
+					`the pod creation`),
+		),
+		Entry("update setting the multus default network annotation is denied",
+			dummyPodForVM("", vmName),
+			dummyPodForVMWithAnnotation("", vmName,
+				map[string]string{config.MultusDefaultNetAnnotation: mutatedDefaultNetwork}),
+			deniedResponse(ReasonImmutableNetworkRequests, multusDefaultNetworkAnnotationField,
+				`pod "ns1/pod1" annotation "v1.multus-cni.io/default-network" cannot change after the pod creation`),
+		),
+		Entry("update removing the legacy OVN primary network IPAMClaim annotation is denied",
+			dummyPodForVMWithAnnotation("", vmName,
+				map[string]string{config.OVNPrimaryNetworkIPAMClaimAnnotation: "vm1.podnet"}),
+			dummyPodForVM("", vmName),
+			deniedResponse(ReasonImmutableNetworkRequests, "metadata.annotations[k8s.ovn.org/primary-udn-ipamclaim]",
+				`pod "ns1/pod1" annotation "k8s.ovn.org/primary-udn-ipamclaim" cannot change after the pod creation`),
+		),
+		Entry("update removing the VM annotation along with changing the network selection elements is denied",
+			dummyPodForVM(mutatedNetworks, vmName),
+			withoutVMAnnotation(dummyPodForVM(`[{"name":"othernet","namespace":"ns1"}]`, vmName)),
+			deniedResponse(ReasonImmutableNetworkRequests, "metadata.annotations[k8s.v1.cni.cncf.io/networks]",
+				`pod "ns1/pod1" network selection elements IPAMClaim references cannot change after the pod creation`),
+		),
+		Entry("update of a pod not belonging to a VM is allowed",
+			&corev1.Pod{},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{nadv1.NetworkAttachmentAnnot: `[{"name":"othernet"}]`},
+			}},
+			admissionv1.AdmissionResponse{
+				Allowed: true,
+				Result: &metav1.Status{
+					Message: "not a VM",
+					Code:    http.StatusOK,
+				},
+			},
+		),
+	)
 })
 
+const (
+	mutatedNetworks       = `[{"name":"supadupanet","namespace":"ns1","ipam-claim-reference":"vm1.randomnet"}]`
+	mutatedDefaultNetwork = `[{"name":"ovn-kubernetes","namespace":"randomNS","ipam-claim-reference":"vm1.podnet"}]`
+)
+
 func dummyVM(nadName string) *virtv1.VirtualMachine {
 	return &virtv1.VirtualMachine{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "vm1",
 			Namespace: "ns1",
+			UID:       dummyVMUID,
 		},
 		Spec: virtv1.VirtualMachineSpec{
 			Template: &virtv1.VirtualMachineInstanceTemplateSpec{
@@ -428,6 +1462,7 @@ func dummyVMI(nadName string, opts ...VMCreationOptions) *virtv1.VirtualMachineI
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "vm1",
 			Namespace: "ns1",
+			UID:       dummyVMIUID,
 		},
 		Spec: dummyVMISpec(nadName),
 	}
@@ -495,6 +1530,11 @@ func dummyPrimaryNetworkNAD(nadName string) *nadv1.NetworkAttachmentDefinition {
 	"subnets": "192.168.0.0/16,fd12:1234::123/64"
 }`)
 }
+func dummyPrimaryNetworkNADWithoutPersistentIPs(nadName string) *nadv1.NetworkAttachmentDefinition {
+	return dummyNADWithConfig(nadName+"primary",
+		`{"name": "primarynet", "role": "primary", "subnets": "192.168.0.0/16,fd12:1234::123/64"}`)
+}
+
 func dummyNADWithoutPersistentIPs(nadName string) *nadv1.NetworkAttachmentDefinition {
 	return dummyNADWithConfig(nadName, `{"name": "goodnet"}`)
 }
@@ -503,6 +1543,16 @@ func podAdmissionRequest(pod *corev1.Pod) admission.Request {
 	return podAdmissionRequestWithOperation(pod, admissionv1.Create)
 }
 
+func podUpdateAdmissionRequest(oldPod *corev1.Pod, pod *corev1.Pod) admission.Request {
+	request := podAdmissionRequestWithOperation(pod, admissionv1.Update)
+	rawOldPod, err := json.Marshal(oldPod)
+	if err != nil {
+		return request
+	}
+	request.OldObject = runtime.RawExtension{Raw: rawOldPod}
+	return request
+}
+
 func podAdmissionRequestWithOperation(pod *corev1.Pod, operation admissionv1.Operation) admission.Request {
 	rawPod, err := json.Marshal(pod)
 	if err != nil {
@@ -519,14 +1569,31 @@ func podAdmissionRequestWithOperation(pod *corev1.Pod, operation admissionv1.Ope
 }
 
 func dummyPodForVM(nadName string, vmName string) *corev1.Pod {
-	return pod(nadName, map[string]string{
+	return launcherPod(pod(nadName, map[string]string{
 		"kubevirt.io/domain": vmName,
-	})
+	}), vmName, dummyVMIUID)
 }
 
 func dummyPodForVMWithAnnotation(nadName string, vmName string, annotations map[string]string) *corev1.Pod {
 	annotations["kubevirt.io/domain"] = vmName
-	return pod(nadName, annotations)
+	return launcherPod(pod(nadName, annotations), vmName, dummyVMIUID)
+}
+
+// launcherPod sets the owner reference and labels KubeVirt sets on the virt-launcher pod of the VMI
+func launcherPod(pod *corev1.Pod, vmiName string, vmiUID apitypes.UID) *corev1.Pod {
+	pod.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion:         virtv1.GroupVersion.String(),
+		Kind:               "VirtualMachineInstance",
+		Name:               vmiName,
+		UID:                vmiUID,
+		Controller:         ptr.To(true),
+		BlockOwnerDeletion: ptr.To(true),
+	}}
+	pod.Labels = map[string]string{
+		virtv1.AppLabel:       "virt-launcher",
+		virtv1.CreatedByLabel: string(vmiUID),
+	}
+	return pod
 }
 
 func dummyPod(nadName string) *corev1.Pod {
@@ -550,6 +1617,98 @@ func pod(nadName string, annotations map[string]string) *corev1.Pod {
 	}
 }
 
+func withoutInterface(ifaceName string) VMCreationOptions {
+	return func(vmi *virtv1.VirtualMachineInstance) error {
+		var interfaces []virtv1.Interface
+		for _, iface := range vmi.Spec.Domain.Devices.Interfaces {
+			if iface.Name != ifaceName {
+				interfaces = append(interfaces, iface)
+			}
+		}
+		vmi.Spec.Domain.Devices.Interfaces = interfaces
+		return nil
+	}
+}
+
+// withGeneratedName drops the pod name in favor of a generated one, which is not set yet when the pod is admitted
+func withGeneratedName(pod *corev1.Pod) *corev1.Pod {
+	pod.GenerateName = "virt-launcher-" + pod.Annotations[vmAnnotation] + "-"
+	pod.Name = ""
+	return pod
+}
+
+func withoutLabels(pod *corev1.Pod) *corev1.Pod {
+	pod.Labels = nil
+	return pod
+}
+
+func withoutVMAnnotation(pod *corev1.Pod) *corev1.Pod {
+	delete(pod.Annotations, vmAnnotation)
+	return pod
+}
+
+func ipamClaimOwnedBy(name, networkName, vmName string, vmUID apitypes.UID) *ipamclaimsapi.IPAMClaim {
+	return &ipamclaimsapi.IPAMClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      name,
+			Labels:    map[string]string{virtv1.VirtualMachineLabel: vmName},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion:         virtv1.VirtualMachineGroupVersionKind.GroupVersion().String(),
+				Kind:               virtv1.VirtualMachineGroupVersionKind.Kind,
+				Name:               vmName,
+				UID:                vmUID,
+				Controller:         ptr.To(true),
+				BlockOwnerDeletion: ptr.To(true),
+			}},
+			Finalizers: []string{claims.KubevirtVMFinalizer},
+		},
+		Spec: ipamclaimsapi.IPAMClaimSpec{Network: networkName},
+	}
+}
+
+const (
+	trustedRequesters     = "serviceaccount:kubevirt/migrator, group:network-admins, user:migration-controller"
+	trustedDefaultNetwork = `[{"name":"default","namespace":"randomNS","interface":"eth0"}]`
+)
+
+const vmiNotResolvedMessage = `VMI "ns1/vm1" could not be resolved, the pod is admitted without requesting ` +
+	`persistent IPs: failed to access the VMI running in pod "ns1/pod1": ` +
+	`virtualmachineinstances.kubevirt.io "vm1" not found`
+
+const vmiNotResolvedEvent = `Warning VMIUnresolved VMI "ns1/vm1" could not be resolved, the pod "ns1/pod1" is ` +
+	`admitted without requesting persistent IPs: failed to access the VMI running in pod "ns1/pod1": ` +
+	`virtualmachineinstances.kubevirt.io "vm1" not found ` +
+	`involvedObject{kind=VirtualMachineInstance,apiVersion=kubevirt.io/v1}`
+
+func namespaceWithVMIResolutionPolicy(name string, policy VMIResolutionPolicy) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{config.VMIResolutionPolicyAnnotation: string(policy)},
+		},
+	}
+}
+
+const (
+	dummyVMUID  apitypes.UID = "5d2a9c64-1b7e-4f38-a0c5-8e3f6b1d7a42"
+	dummyVMIUID apitypes.UID = "c8e1b2f0-7a3d-4e5b-9f61-2d4c7b8a9e10"
+)
+
+// withOwningVM sets the VMI controller owner reference KubeVirt sets on the VMIs of the dummy VM
+func withOwningVM() VMCreationOptions {
+	return func(vmi *virtv1.VirtualMachineInstance) error {
+		vmi.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: virtv1.VirtualMachineGroupVersionKind.GroupVersion().String(),
+			Kind:       virtv1.VirtualMachineGroupVersionKind.Kind,
+			Name:       vmi.Name,
+			UID:        dummyVMUID,
+			Controller: ptr.To(true),
+		}}
+		return nil
+	}
+}
+
 type VMCreationOptions func(*virtv1.VirtualMachineInstance) error
 
 func WithIPRequests(logicalNetworkName string, ips ...string) VMCreationOptions {
@@ -587,3 +1746,60 @@ func WithMACRequest(logicalNetworkName string, macAddress string) VMCreationOpti
 		return fmt.Errorf("interface %q not found", logicalNetworkName)
 	}
 }
+
+func withSecondaryNetwork(logicalNetworkName string, nadName string) VMCreationOptions {
+	return func(vm *virtv1.VirtualMachineInstance) error {
+		vm.Spec.Networks = append(vm.Spec.Networks, virtv1.Network{
+			Name: logicalNetworkName,
+			NetworkSource: virtv1.NetworkSource{
+				Multus: &virtv1.MultusNetwork{NetworkName: nadName},
+			},
+		})
+		vm.Spec.Domain.Devices.Interfaces = append(
+			vm.Spec.Domain.Devices.Interfaces,
+			virtv1.Interface{Name: logicalNetworkName},
+		)
+		return nil
+	}
+}
+
+func withMultusDefaultNetwork(logicalNetworkName string, nadName string) VMCreationOptions {
+	return func(vm *virtv1.VirtualMachineInstance) error {
+		vm.Spec.Networks = []virtv1.Network{{
+			Name: logicalNetworkName,
+			NetworkSource: virtv1.NetworkSource{
+				Multus: &virtv1.MultusNetwork{NetworkName: nadName, Default: true},
+			},
+		}}
+		vm.Spec.Domain.Devices.Interfaces = []virtv1.Interface{{Name: logicalNetworkName}}
+		return nil
+	}
+}
+
+func withNADIndexes(clientBuilder *fake.ClientBuilder, nadConfigs *nads.ConfigCache) *fake.ClientBuilder {
+	for indexName, extractValue := range nadConfigs.Indexers() {
+		clientBuilder = clientBuilder.WithIndex(&nadv1.NetworkAttachmentDefinition{}, indexName, extractValue)
+	}
+	return clientBuilder
+}
+
+func withoutPodNetwork() VMCreationOptions {
+	return func(vm *virtv1.VirtualMachineInstance) error {
+		var networks []virtv1.Network
+		for _, network := range vm.Spec.Networks {
+			if network.Pod == nil {
+				networks = append(networks, network)
+				continue
+			}
+			var interfaces []virtv1.Interface
+			for _, iface := range vm.Spec.Domain.Devices.Interfaces {
+				if iface.Name != network.Name {
+					interfaces = append(interfaces, iface)
+				}
+			}
+			vm.Spec.Domain.Devices.Interfaces = interfaces
+		}
+		vm.Spec.Networks = networks
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+package ipamclaimswebhook
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	netutils "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/utils"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+// immutableNetworkAnnotations are the launcher pod annotations holding its network requests: they are defined when
+// the pod is created, and read by the CNI plugins when its sandbox is created, hence cannot change afterwards
+var immutableNetworkAnnotations = []string{
+	v1.NetworkAttachmentAnnot,
+	config.MultusDefaultNetAnnotation,
+	config.OVNPrimaryNetworkIPAMClaimAnnotation,
+}
+
+// validatePodUpdate ensures an update of the launcher pod does not change its network requests
+func validatePodUpdate(oldPod, pod *corev1.Pod) error {
+	podKey := podReference(pod)
+	for _, annotation := range immutableNetworkAnnotations {
+		oldValue, oldExists := oldPod.Annotations[annotation]
+		value, exists := pod.Annotations[annotation]
+		if oldExists == exists && oldValue == value {
+			continue
+		}
+
+		message := fmt.Sprintf("pod %q annotation %q cannot change after the pod creation", podKey, annotation)
+		if annotation == v1.NetworkAttachmentAnnot &&
+			!slices.Equal(networkSelectionElementsClaimReferences(oldPod), networkSelectionElementsClaimReferences(pod)) {
+			message = fmt.Sprintf("pod %q network selection elements IPAMClaim references cannot change after the "+
+				"pod creation", podKey)
+		}
+		return ValidationError{
+			Reason:  ReasonImmutableNetworkRequests,
+			Field:   fmt.Sprintf("metadata.annotations[%s]", annotation),
+			Message: message,
+		}
+	}
+	return nil
+}
+
+// networkSelectionElementsClaimReferences returns the IPAMClaims referenced by the pod network selection elements;
+// an unparsable annotation holds no reference
+func networkSelectionElementsClaimReferences(pod *corev1.Pod) []string {
+	networkSelectionElements, err := netutils.ParsePodNetworkAnnotation(pod)
+	if err != nil {
+		return nil
+	}
+	var ipamClaimNames []string
+	for _, networkSelectionElement := range networkSelectionElements {
+		if networkSelectionElement.IPAMClaimReference != "" {
+			ipamClaimNames = append(ipamClaimNames, networkSelectionElement.IPAMClaimReference)
+		}
+	}
+	return ipamClaimNames
+}
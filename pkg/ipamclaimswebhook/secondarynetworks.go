@@ -0,0 +1,93 @@
+package ipamclaimswebhook
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+)
+
+const (
+	hashedPodIfacePrefix  = "pod"
+	ordinalPodIfacePrefix = "net"
+)
+
+// vmiSecondaryNetwork is a kubevirt VM secondary network, along with the NAD it is attached to
+type vmiSecondaryNetwork struct {
+	nadKey        types.NamespacedName
+	networkName   string
+	podIfaceNames []string
+}
+
+// secondaryNetworksMatcher matches the launcher pod network selection elements with the kubevirt VM
+// secondary networks; each VM network is matched at most once, thus several VM networks may use the same NAD.
+type secondaryNetworksMatcher struct {
+	networks []vmiSecondaryNetwork
+	matched  []bool
+}
+
+func newSecondaryNetworksMatcher(vmi *virtv1.VirtualMachineInstance) *secondaryNetworksMatcher {
+	networks := vmiSecondaryNetworks(vmi)
+	return &secondaryNetworksMatcher{
+		networks: networks,
+		matched:  make([]bool, len(networks)),
+	}
+}
+
+// match returns the name of the VM network the network selection element refers to.
+// The network selection element is matched by its pod interface name when it requests one; otherwise, by
+// the order of the VM networks attached to its NAD.
+func (m *secondaryNetworksMatcher) match(
+	nadKey types.NamespacedName,
+	networkSelectionElement *v1.NetworkSelectionElement,
+) (string, bool) {
+	for i, network := range m.networks {
+		if m.matched[i] || network.nadKey != nadKey {
+			continue
+		}
+		if networkSelectionElement.InterfaceRequest != "" &&
+			!slices.Contains(network.podIfaceNames, networkSelectionElement.InterfaceRequest) {
+			continue
+		}
+		m.matched[i] = true
+		return network.networkName, true
+	}
+	return "", false
+}
+
+// returns the kubevirt VM secondary networks, in the order they are defined
+func vmiSecondaryNetworks(vmi *virtv1.VirtualMachineInstance) []vmiSecondaryNetwork {
+	var secondaryNetworks []vmiSecondaryNetwork
+	for _, network := range vmi.Spec.Networks {
+		if network.Multus == nil {
+			continue
+		}
+		if network.Multus.Default {
+			continue
+		}
+
+		secondaryNetworks = append(secondaryNetworks, vmiSecondaryNetwork{
+			nadKey:      nads.MultusNetworkNADKey(vmi.Namespace, network.Multus.NetworkName),
+			networkName: network.Name,
+			podIfaceNames: []string{
+				hashedPodIfaceName(network.Name),
+				fmt.Sprintf("%s%d", ordinalPodIfacePrefix, len(secondaryNetworks)+1),
+			},
+		})
+	}
+
+	return secondaryNetworks
+}
+
+// hashedPodIfaceName returns the pod interface name KubeVirt generates for a secondary network
+func hashedPodIfaceName(networkName string) string {
+	hash := sha256.Sum256([]byte(networkName))
+	return fmt.Sprintf("%s%x", hashedPodIfacePrefix, hash)[:len(hashedPodIfacePrefix)+11]
+}
@@ -0,0 +1,58 @@
+package ipamclaimswebhook
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+)
+
+var _ = Describe("secondary networks matcher", func() {
+	const nadName = "ns1/supadupanet"
+
+	var (
+		matcher *secondaryNetworksMatcher
+		nadKey  = types.NamespacedName{Namespace: "ns1", Name: "supadupanet"}
+	)
+
+	BeforeEach(func() {
+		matcher = newSecondaryNetworksMatcher(dummyVMI(nadName, withSecondaryNetwork("bondnet", nadName)))
+	})
+
+	matchedNetwork := func(networkSelectionElement *v1.NetworkSelectionElement) string {
+		networkName, found := matcher.match(nadKey, networkSelectionElement)
+		Expect(found).To(BeTrue())
+		return networkName
+	}
+
+	It("matches the VM networks attached to the same NAD by order", func() {
+		Expect(matchedNetwork(&v1.NetworkSelectionElement{})).To(Equal("randomnet"))
+		Expect(matchedNetwork(&v1.NetworkSelectionElement{})).To(Equal("bondnet"))
+		_, found := matcher.match(nadKey, &v1.NetworkSelectionElement{})
+		Expect(found).To(BeFalse())
+	})
+
+	It("matches the VM networks by their hashed pod interface name", func() {
+		Expect(matchedNetwork(&v1.NetworkSelectionElement{
+			InterfaceRequest: hashedPodIfaceName("bondnet"),
+		})).To(Equal("bondnet"))
+		Expect(matchedNetwork(&v1.NetworkSelectionElement{})).To(Equal("randomnet"))
+	})
+
+	It("matches the VM networks by their ordinal pod interface name", func() {
+		Expect(matchedNetwork(&v1.NetworkSelectionElement{InterfaceRequest: "net2"})).To(Equal("bondnet"))
+		Expect(matchedNetwork(&v1.NetworkSelectionElement{InterfaceRequest: "net1"})).To(Equal("randomnet"))
+	})
+
+	It("does not match network selection elements of other NADs", func() {
+		_, found := matcher.match(types.NamespacedName{Namespace: "ns1", Name: "othernet"}, &v1.NetworkSelectionElement{})
+		Expect(found).To(BeFalse())
+	})
+
+	It("generates the pod interface names as KubeVirt does", func() {
+		Expect(hashedPodIfaceName("bondnet")).To(HaveLen(14))
+		Expect(hashedPodIfaceName("bondnet")).To(HavePrefix("pod"))
+	})
+})
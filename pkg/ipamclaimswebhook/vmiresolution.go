@@ -0,0 +1,99 @@
+package ipamclaimswebhook
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/metrics"
+)
+
+// VMIResolutionPolicy defines how the launcher pods whose VMI cannot be resolved are admitted
+type VMIResolutionPolicy string
+
+const (
+	// VMIResolutionPolicyStrict fails the admission of the launcher pods whose VMI cannot be resolved
+	VMIResolutionPolicyStrict VMIResolutionPolicy = "strict"
+	// VMIResolutionPolicyBestEffort admits the launcher pods whose VMI cannot be resolved without mutating them,
+	// i.e. without requesting persistent IPs
+	VMIResolutionPolicyBestEffort VMIResolutionPolicy = "best-effort"
+)
+
+// VMIUnresolvedReason is the reason of the Events reporting a pod admitted without resolving its VMI
+const VMIUnresolvedReason = "VMIUnresolved"
+
+// ParseVMIResolutionPolicy returns the policy named by policyName
+func ParseVMIResolutionPolicy(policyName string) (VMIResolutionPolicy, error) {
+	switch policy := VMIResolutionPolicy(policyName); policy {
+	case VMIResolutionPolicyStrict, VMIResolutionPolicyBestEffort:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown VMI resolution policy %q, expected %q or %q",
+			policyName, VMIResolutionPolicyStrict, VMIResolutionPolicyBestEffort)
+	}
+}
+
+// vmiResolutionPolicy returns the policy applying to the pods of the namespace: the namespace annotation overrides
+// the global policy, which applies when the namespace cannot be read or the annotation is invalid
+func (a *IPAMClaimsValet) vmiResolutionPolicy(ctx context.Context, namespace string) VMIResolutionPolicy {
+	log := logf.FromContext(ctx)
+
+	ns := &corev1.Namespace{}
+	if err := a.Client.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		log.Error(err, "failed to read the namespace VMI resolution policy, using the global policy",
+			"policy", a.vmiResolution)
+		return a.vmiResolution
+	}
+	policyName, isOverridden := ns.Annotations[config.VMIResolutionPolicyAnnotation]
+	if !isOverridden {
+		return a.vmiResolution
+	}
+	policy, err := ParseVMIResolutionPolicy(policyName)
+	if err != nil {
+		log.Error(err, "invalid namespace VMI resolution policy, using the global policy", "policy", a.vmiResolution)
+		return a.vmiResolution
+	}
+	return policy
+}
+
+// handleUnresolvedVMI answers the admission of a launcher pod whose VMI cannot be resolved according to the pod
+// namespace policy: the pod is either rejected, or admitted without mutation - which is reported by a warning, an
+// Event on the VMI, and a metric.
+func (a *IPAMClaimsValet) handleUnresolvedVMI(
+	ctx context.Context,
+	pod *corev1.Pod,
+	vmKey types.NamespacedName,
+	resolutionErr error,
+	warnings *admissionWarnings,
+) admission.Response {
+	policy := a.vmiResolutionPolicy(ctx, pod.Namespace)
+	metrics.ReportVMIResolutionFailure(pod.Namespace, string(policy))
+	if policy != VMIResolutionPolicyBestEffort {
+		return errorResponse(resolutionErr)
+	}
+
+	logf.FromContext(ctx).Info("admitting the launcher pod without mutation since its VMI cannot be resolved",
+		"vmi", vmKey, "reason", resolutionErr.Error())
+	warnings.add("VMI %q could not be resolved, the pod is admitted without requesting persistent IPs: %v",
+		vmKey.String(), resolutionErr)
+	// the Event is recorded on the VMI since the pods created with a generated name have no name yet
+	vmiReference := &corev1.ObjectReference{
+		APIVersion: virtv1.GroupVersion.String(),
+		Kind:       "VirtualMachineInstance",
+		Namespace:  vmKey.Namespace,
+		Name:       vmKey.Name,
+	}
+	a.recorder.Eventf(vmiReference, corev1.EventTypeWarning, VMIUnresolvedReason,
+		"VMI %q could not be resolved, the pod %q is admitted without requesting persistent IPs: %v",
+		vmKey.String(), podReference(pod), resolutionErr)
+	return admission.Allowed("VMI not resolved, the pod is admitted without mutation")
+}
@@ -0,0 +1,20 @@
+package ipamclaimswebhook
+
+import "fmt"
+
+// admissionWarnings collects the network requests which cannot be honored, so they are returned to the user along
+// with the admission response instead of only being logged
+type admissionWarnings []string
+
+func (w *admissionWarnings) add(format string, args ...any) {
+	*w = append(*w, fmt.Sprintf(format, args...))
+}
+
+func (w *admissionWarnings) addNADNotFound(nadName string) {
+	w.add("NAD %q not found, the pod network attachments are not mutated", nadName)
+}
+
+func (w *admissionWarnings) addNotPersistedIPRequests(ifaceName, networkName string) {
+	w.add("interface %q requests IPs on network %q which does not allow persistent IPs, "+
+		"the IPs are requested without being persisted", ifaceName, networkName)
+}
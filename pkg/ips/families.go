@@ -2,7 +2,6 @@ package ips
 
 import (
 	"fmt"
-	"net"
 	"strings"
 )
 
@@ -22,12 +21,12 @@ func SeparateSubnetsByFamily(subnets string) ([]string, []string, error) {
 		}
 
 		// Parse the subnet to determine its IP family
-		_, ipNet, err := net.ParseCIDR(subnet)
+		parsedSubnet, err := ParseSubnet(subnet)
 		if err != nil {
 			return nil, nil, fmt.Errorf("invalid subnet format: %s", subnet)
 		}
 
-		if ipNet.IP.To4() != nil {
+		if parsedSubnet.CIDR.IP.To4() != nil {
 			ipv4Subnets = append(ipv4Subnets, subnet)
 		} else {
 			ipv6Subnets = append(ipv6Subnets, subnet)
@@ -37,12 +36,15 @@ func SeparateSubnetsByFamily(subnets string) ([]string, []string, error) {
 	return ipv4Subnets, ipv6Subnets, nil
 }
 
-func IsIPv4(ip string) bool {
-	parsedIP := net.ParseIP(ip)
-	return parsedIP != nil && parsedIP.To4() != nil
+// IsIPv4 reports whether the requested IP is an IPv4 address, as the IP requests are resolved: the requests written
+// in CIDR form and the IPv4-mapped IPv6 addresses are IPv4 addresses
+func IsIPv4(ipRequest string) bool {
+	ip, _, err := parseIPRequest(ipRequest)
+	return err == nil && ip.To4() != nil
 }
 
-func IsIPv6(ip string) bool {
-	parsedIP := net.ParseIP(ip)
-	return parsedIP != nil && parsedIP.To4() == nil
+// IsIPv6 reports whether the requested IP is an IPv6 address, as the IP requests are resolved
+func IsIPv6(ipRequest string) bool {
+	ip, _, err := parseIPRequest(ipRequest)
+	return err == nil && ip.To4() == nil
 }
@@ -81,6 +81,21 @@ func TestSeparateSubnetsByFamily(t *testing.T) {
 			expectedError: true,
 			errorContains: "invalid subnet format: 2001:db8::",
 		},
+		{
+			name:          "layer3 subnets with host prefix",
+			subnets:       "10.128.0.0/16/24,fd12:1234::/56/64",
+			expectedIPv4:  []string{"10.128.0.0/16/24"},
+			expectedIPv6:  []string{"fd12:1234::/56/64"},
+			expectedError: false,
+		},
+		{
+			name:          "layer3 subnet with a host prefix shorter than the subnet prefix",
+			subnets:       "10.128.0.0/16/8",
+			expectedIPv4:  nil,
+			expectedIPv6:  nil,
+			expectedError: true,
+			errorContains: "invalid subnet format: 10.128.0.0/16/8",
+		},
 		{
 			name:          "multiple IPv4 subnets only",
 			subnets:       "192.168.1.0/24,10.0.0.0/8,172.16.0.0/12",
@@ -186,7 +201,17 @@ func TestIsIPv4(t *testing.T) {
 		{
 			name:     "IPv4-mapped IPv6 address",
 			ip:       "::ffff:192.168.1.1",
-			expected: true, // Go's net package treats this as IPv4
+			expected: true, // IPv4-mapped IPv6 addresses are unmapped, as for the IP requests
+		},
+		{
+			name:     "IPv4 address in CIDR form",
+			ip:       "192.168.1.1/24",
+			expected: true,
+		},
+		{
+			name:     "IPv4-mapped IPv6 address in CIDR form",
+			ip:       "::ffff:192.168.1.1/120",
+			expected: true,
 		},
 		{
 			name:     "invalid IP address",
@@ -248,7 +273,17 @@ func TestIsIPv6(t *testing.T) {
 		{
 			name:     "IPv4-mapped IPv6 address",
 			ip:       "::ffff:192.168.1.1",
-			expected: false, // Go's net package treats this as IPv4, so IsIPv6 returns false
+			expected: false, // IPv4-mapped IPv6 addresses are unmapped, as for the IP requests
+		},
+		{
+			name:     "IPv6 address in CIDR form",
+			ip:       "2001:db8::1/64",
+			expected: true,
+		},
+		{
+			name:     "IPv4-mapped IPv6 address in CIDR form",
+			ip:       "::ffff:192.168.1.1/120",
+			expected: false,
 		},
 		{
 			name:     "IPv4 address",
@@ -0,0 +1,113 @@
+package ips
+
+import (
+	"fmt"
+	"net"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+// ValidateGatewayRequests ensures the gateways requested for an interface can be used on the network: they are only
+// requested along with the default route, at most one per family, each of them within one of the network subnets.
+func ValidateGatewayRequests(requests InterfaceRequests, netConfig *config.RelevantConfig) error {
+	_, err := gatewayRequests(requests, netConfig)
+	return err
+}
+
+// VmiInterfaceGatewayRequests returns the gateways of the default route requested through the VMI interface; nil
+// when the interface should not hold the default route.
+func VmiInterfaceGatewayRequests(
+	vmi *virtv1.VirtualMachineInstance,
+	ifaceName string,
+	netConfig *config.RelevantConfig,
+) ([]net.IP, error) {
+	requests, err := ParseInterfaceRequests(vmi.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	return gatewayRequests(requests[ifaceName], netConfig)
+}
+
+// gatewayRequests resolves the requested gateways; when the default route is requested without any gateway, the
+// gateway of each IP family is the gateway address of the first network subnet of that family, i.e. its configured
+// gateway or else, on the OVN-Kubernetes primary networks, its first host address. The secondary networks without a
+// configured gateway require the gateways to be requested along with the default route.
+func gatewayRequests(requests InterfaceRequests, netConfig *config.RelevantConfig) ([]net.IP, error) {
+	if !requests.DefaultRoute {
+		if len(requests.Gateways) > 0 {
+			return nil, fmt.Errorf("gateways %v requested without the default route", requests.Gateways)
+		}
+		return nil, nil
+	}
+
+	ipv4Subnets, ipv6Subnets, err := SeparateSubnetsByFamily(netConfig.Subnets)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests.Gateways) == 0 {
+		return subnetGateways(netConfig, ipv4Subnets, ipv6Subnets)
+	}
+
+	gateways := make([]net.IP, 0, len(requests.Gateways))
+	var hasIPv4Gateway, hasIPv6Gateway bool
+	for _, gatewayRequest := range requests.Gateways {
+		gateway, _, err := resolveIPRequest(gatewayRequest, ipv4Subnets, ipv6Subnets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gateway request: %w", err)
+		}
+		if gateway.To4() != nil {
+			if hasIPv4Gateway {
+				return nil, fmt.Errorf("more than one IPv4 gateway requested: %v", requests.Gateways)
+			}
+			hasIPv4Gateway = true
+		} else {
+			if hasIPv6Gateway {
+				return nil, fmt.Errorf("more than one IPv6 gateway requested: %v", requests.Gateways)
+			}
+			hasIPv6Gateway = true
+		}
+		gateways = append(gateways, gateway)
+	}
+	return gateways, nil
+}
+
+func subnetGateways(netConfig *config.RelevantConfig, ipv4Subnets, ipv6Subnets []string) ([]net.IP, error) {
+	if netConfig.Topology == config.TopologyLayer3 {
+		return nil, underivableLayer3GatewayError(netConfig.Name)
+	}
+
+	configuredGateways, err := parseGateways(netConfig.Gateways)
+	if err != nil {
+		return nil, err
+	}
+	var gateways []net.IP
+	for _, familySubnets := range [][]string{ipv4Subnets, ipv6Subnets} {
+		if len(familySubnets) == 0 {
+			continue
+		}
+		subnet, err := ParseSubnet(familySubnets[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet format: %s", familySubnets[0])
+		}
+		if subnet.HostPrefix != 0 {
+			return nil, underivableLayer3GatewayError(netConfig.Name)
+		}
+		gateway := subnetGateway(netConfig, configuredGateways, subnet.CIDR)
+		if gateway == nil {
+			return nil, fmt.Errorf("the subnet %s of network %q has no gateway, hence the default route requires "+
+				"the gateways to be requested", familySubnets[0], netConfig.Name)
+		}
+		gateways = append(gateways, gateway)
+	}
+	if len(gateways) == 0 {
+		return nil, fmt.Errorf("no subnet to derive the default route gateway from on network %q", netConfig.Name)
+	}
+	return gateways, nil
+}
+
+func underivableLayer3GatewayError(networkName string) error {
+	return fmt.Errorf("%w on the layer3 network %q: the default route gateway depends on the node the VM runs on, "+
+		"hence cannot be derived", ErrUnsupportedIPRequests, networkName)
+}
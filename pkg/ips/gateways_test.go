@@ -0,0 +1,89 @@
+package ips
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+var _ = Describe("VmiInterfaceGatewayRequests", func() {
+	const ifaceName = "podnet"
+
+	DescribeTable("returns the default route gateways requested for the interface",
+		func(netConfig *config.RelevantConfig, rawRequests string, expectedGateways []net.IP) {
+			vmi := &virtv1.VirtualMachineInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{config.IPRequestsAnnotation: rawRequests},
+				},
+			}
+			Expect(VmiInterfaceGatewayRequests(vmi, ifaceName, netConfig)).To(
+				Equal(expectedGateways),
+			)
+		},
+		Entry("no default route", &config.RelevantConfig{Subnets: "192.168.0.0/16"}, `{"podnet": ["192.168.1.10"]}`, nil),
+		Entry("default route requested on another interface", &config.RelevantConfig{Subnets: "192.168.0.0/16"},
+			`{"othernet": {"defaultRoute": true}}`, nil),
+		Entry("requested gateways", &config.RelevantConfig{Subnets: "192.168.0.0/16,fd12:1234::/64"},
+			`{"podnet": {"defaultRoute": true, "gateways": ["192.168.0.254", "fd12:1234::fe"]}}`,
+			[]net.IP{net.ParseIP("192.168.0.254").To4(), net.ParseIP("fd12:1234::fe")}),
+		Entry("first host address of the first subnet of each family on a primary network",
+			&config.RelevantConfig{Role: config.NetworkRolePrimary, Subnets: "192.168.0.0/16,10.0.0.0/8,fd12:1234::/64"},
+			`{"podnet": {"defaultRoute": true}}`,
+			[]net.IP{net.ParseIP("192.168.0.1").To4(), net.ParseIP("fd12:1234::1")}),
+		Entry("configured gateway of the first subnet of each family on a secondary network",
+			&config.RelevantConfig{Subnets: "192.168.0.0/16,fd12:1234::/64", Gateways: "192.168.0.254,fd12:1234::fe"},
+			`{"podnet": {"defaultRoute": true}}`,
+			[]net.IP{net.ParseIP("192.168.0.254").To4(), net.ParseIP("fd12:1234::fe")}),
+		Entry("configured gateway, or else first host address, on a primary network",
+			&config.RelevantConfig{
+				Role: config.NetworkRolePrimary, Subnets: "192.168.0.0/16,fd12:1234::/64", Gateways: "192.168.0.254",
+			},
+			`{"podnet": {"defaultRoute": true}}`,
+			[]net.IP{net.ParseIP("192.168.0.254").To4(), net.ParseIP("fd12:1234::1")}),
+	)
+})
+
+var _ = Describe("ValidateGatewayRequests", func() {
+	DescribeTable("rejects the gateway requests which cannot be used on the network",
+		func(netConfig *config.RelevantConfig, requests InterfaceRequests, expectedErr string) {
+			Expect(ValidateGatewayRequests(requests, netConfig)).To(MatchError(ContainSubstring(expectedErr)))
+		},
+		Entry("gateways without the default route", &config.RelevantConfig{Subnets: "192.168.0.0/16"},
+			InterfaceRequests{Gateways: []string{"192.168.0.1"}},
+			"gateways [192.168.0.1] requested without the default route"),
+		Entry("invalid gateway", &config.RelevantConfig{Subnets: "192.168.0.0/16"},
+			InterfaceRequests{DefaultRoute: true, Gateways: []string{"not.an.ip"}},
+			"invalid gateway request"),
+		Entry("gateway outside the subnets", &config.RelevantConfig{Subnets: "192.168.0.0/16"},
+			InterfaceRequests{DefaultRoute: true, Gateways: []string{"172.16.0.1"}},
+			"is not within the network subnets"),
+		Entry("two IPv4 gateways", &config.RelevantConfig{Subnets: "192.168.0.0/16"},
+			InterfaceRequests{DefaultRoute: true, Gateways: []string{"192.168.0.1", "192.168.0.2"}},
+			"more than one IPv4 gateway requested"),
+		Entry("secondary network subnet without gateway",
+			&config.RelevantConfig{Name: "net", Subnets: "192.168.0.0/16,fd12:1234::/64", Gateways: "192.168.0.254"},
+			InterfaceRequests{DefaultRoute: true},
+			`the subnet fd12:1234::/64 of network "net" has no gateway, hence the default route requires the `+
+				`gateways to be requested`),
+		Entry("network without subnets", &config.RelevantConfig{Name: "net"},
+			InterfaceRequests{DefaultRoute: true},
+			`no subnet to derive the default route gateway from on network "net"`),
+		Entry("layer3 network", &config.RelevantConfig{Name: "net", Subnets: "192.168.0.0/16/24"},
+			InterfaceRequests{DefaultRoute: true},
+			`on the layer3 network "net": the default route gateway depends on the node the VM runs on`),
+	)
+
+	It("rejects the default route on a layer3 network as unsupported", func() {
+		layer3Config := &config.RelevantConfig{Name: "net", Topology: config.TopologyLayer3, Subnets: "192.168.0.0/16"}
+		Expect(ValidateGatewayRequests(InterfaceRequests{DefaultRoute: true}, layer3Config)).To(
+			MatchError(ErrUnsupportedIPRequests),
+		)
+	})
+})
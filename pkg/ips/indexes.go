@@ -0,0 +1,79 @@
+package ips
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
+
+	virtv1 "kubevirt.io/api/core/v1"
+)
+
+// IP field indexes. The IPAMClaims are indexed by IndexKey, i.e. by their canonical IPs on the name of the network
+// holding them, which identifies the network whatever the namespace of the NADs (or user defined networks) it is
+// attached through. The VMIs are only indexed by their canonical requested IPs: the network of their interfaces
+// depends on NADs (or user defined networks) which may change once the VMI is indexed, hence is resolved on lookup.
+const (
+	ClaimedIPsIndex   = "ipamclaim.status.ips"
+	RequestedIPsIndex = "vmi.requestedIPs"
+)
+
+// IndexKey returns the index key of an IP on a network
+func IndexKey(network, ip string) string {
+	return network + "/" + ip
+}
+
+// ClaimedIPsIndexer indexes the IPAMClaims by the IPs they hold
+func ClaimedIPsIndexer(obj client.Object) []string {
+	claim, isClaim := obj.(*ipamclaimsapi.IPAMClaim)
+	if !isClaim {
+		return nil
+	}
+	var keys []string
+	for _, claimedIP := range claim.Status.IPs {
+		ip, err := CanonicalIP(claimedIP)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, IndexKey(claim.Spec.Network, ip))
+	}
+	return keys
+}
+
+// RequestedIPsIndexer indexes the VMIs by the IPs they request, whatever the interface requesting them
+func RequestedIPsIndexer(obj client.Object) []string {
+	vmi, isVMI := obj.(*virtv1.VirtualMachineInstance)
+	if !isVMI {
+		return nil
+	}
+	ipRequests, err := ParseIPRequests(vmi.Annotations)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, ifaceIPRequests := range ipRequests {
+		for _, ipRequest := range ifaceIPRequests {
+			ip, err := CanonicalIP(ipRequest)
+			if err != nil || slices.Contains(keys, ip) {
+				continue
+			}
+			keys = append(keys, ip)
+		}
+	}
+	return keys
+}
+
+// SetupIndexes registers the IP field indexes on the provided indexer
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &ipamclaimsapi.IPAMClaim{}, ClaimedIPsIndex, ClaimedIPsIndexer); err != nil {
+		return fmt.Errorf("failed to register the IPAMClaim %q index: %w", ClaimedIPsIndex, err)
+	}
+	err := indexer.IndexField(ctx, &virtv1.VirtualMachineInstance{}, RequestedIPsIndex, RequestedIPsIndexer)
+	if err != nil {
+		return fmt.Errorf("failed to register the VMI %q index: %w", RequestedIPsIndex, err)
+	}
+	return nil
+}
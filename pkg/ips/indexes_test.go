@@ -0,0 +1,43 @@
+package ips
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
+
+	virtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+var _ = Describe("IP indexes", func() {
+	It("indexes the IPAMClaims by their canonical IPs on the claimed network", func() {
+		claim := &ipamclaimsapi.IPAMClaim{
+			Spec:   ipamclaimsapi.IPAMClaimSpec{Network: "net1"},
+			Status: ipamclaimsapi.IPAMClaimStatus{IPs: []string{"10.0.0.5/24", "FD12:1234::0200/64", "not-an-ip"}},
+		}
+		Expect(ClaimedIPsIndexer(claim)).To(ConsistOf("net1/10.0.0.5", "net1/fd12:1234::200"))
+	})
+
+	It("indexes the VMIs by their canonical requested IPs, whatever the interface requesting them", func() {
+		vmi := &virtv1.VirtualMachineInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					config.IPRequestsAnnotation: `{"podnet": ["10.0.0.5"], "blue": ["::ffff:10.1.0.5"], ` +
+						`"red": ["10.2.0.5/24", "not-an-ip"], "ghost": ["10.0.0.5"]}`,
+				},
+			},
+		}
+		Expect(RequestedIPsIndexer(vmi)).To(ConsistOf("10.0.0.5", "10.1.0.5", "10.2.0.5"))
+	})
+
+	It("does not index the VMIs with a malformed IP requests annotation", func() {
+		vmi := &virtv1.VirtualMachineInstance{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{config.IPRequestsAnnotation: "{not json}"}},
+		}
+		Expect(RequestedIPsIndexer(vmi)).To(BeEmpty())
+	})
+})
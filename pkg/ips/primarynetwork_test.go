@@ -46,7 +46,7 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 		Context("with valid IPv4 and IPv6 addresses", func() {
 			BeforeEach(func() {
 				ipRequests := map[string][]string{
-					"podnet": {"192.168.1.10", "fd20:1234::200"},
+					"podnet": {"192.168.1.10", "fd12:1234::200"},
 				}
 				rawRequests, _ := json.Marshal(ipRequests)
 				vmi.Annotations = map[string]string{
@@ -57,7 +57,7 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 			It("should return formatted IP addresses with subnet masks", func() {
 				result, err := VmiInterfaceIPRequests(vmi, "podnet", primaryNet)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(result).To(ConsistOf("192.168.1.10/16", "fd20:1234::200/64"))
+				Expect(result).To(ConsistOf("192.168.1.10/16", "fd12:1234::200/64"))
 			})
 		})
 
@@ -83,7 +83,7 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 			BeforeEach(func() {
 				primaryNet.Subnets = "fd12:1234::/64"
 				ipRequests := map[string][]string{
-					"podnet": {"fd20:1234::200", "fd20:1234::300"},
+					"podnet": {"fd12:1234::200", "fd12:1234::300"},
 				}
 				rawRequests, _ := json.Marshal(ipRequests)
 				vmi.Annotations = map[string]string{
@@ -94,7 +94,7 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 			It("should return formatted IPv6 addresses with correct subnet masks", func() {
 				result, err := VmiInterfaceIPRequests(vmi, "podnet", primaryNet)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(result).To(ConsistOf("fd20:1234::200/64", "fd20:1234::300/64"))
+				Expect(result).To(ConsistOf("fd12:1234::200/64", "fd12:1234::300/64"))
 			})
 		})
 
@@ -152,7 +152,7 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 			It("should return error for IPv6 address when no IPv6 subnets configured", func() {
 				primaryNet.Subnets = "192.168.0.0/16" // Only IPv4
 				ipRequests := map[string][]string{
-					"podnet": {"fd20:1234::200"},
+					"podnet": {"fd12:1234::200"},
 				}
 				rawRequests, _ := json.Marshal(ipRequests)
 				vmi.Annotations = map[string]string{
@@ -215,7 +215,7 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 
 			It("should use the first IPv6 subnet for IPv6 addresses", func() {
 				ipRequests := map[string][]string{
-					"podnet": {"fd20:1234::200"},
+					"podnet": {"fd12:1234::200"},
 				}
 				rawRequests, _ := json.Marshal(ipRequests)
 				vmi.Annotations = map[string]string{
@@ -224,8 +224,69 @@ var _ = Describe("VmiInterfaceIPRequests", func() {
 
 				result, err := VmiInterfaceIPRequests(vmi, "podnet", primaryNet)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(result).To(ConsistOf("fd20:1234::200/64"))
+				Expect(result).To(ConsistOf("fd12:1234::200/64"))
 			})
+
+			It("should use the subnet holding the address", func() {
+				ipRequests := map[string][]string{
+					"podnet": {"10.1.1.1", "fd34:5678::10"},
+				}
+				rawRequests, _ := json.Marshal(ipRequests)
+				vmi.Annotations = map[string]string{
+					config.IPRequestsAnnotation: string(rawRequests),
+				}
+
+				result, err := VmiInterfaceIPRequests(vmi, "podnet", primaryNet)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(ConsistOf("10.1.1.1/8", "fd34:5678::10/48"))
+			})
+
+			It("should return error for an address outside every subnet", func() {
+				ipRequests := map[string][]string{
+					"podnet": {"172.16.0.1"},
+				}
+				rawRequests, _ := json.Marshal(ipRequests)
+				vmi.Annotations = map[string]string{
+					config.IPRequestsAnnotation: string(rawRequests),
+				}
+
+				result, err := VmiInterfaceIPRequests(vmi, "podnet", primaryNet)
+				Expect(err).To(MatchError(ContainSubstring("IP request 172.16.0.1 is not within the network subnets")))
+				Expect(result).To(BeNil())
+			})
+		})
+
+		DescribeTable("with requests to canonicalize",
+			func(ipRequest string, expectedIPRequest string) {
+				ipRequests := map[string][]string{
+					"podnet": {ipRequest},
+				}
+				rawRequests, _ := json.Marshal(ipRequests)
+				vmi.Annotations = map[string]string{
+					config.IPRequestsAnnotation: string(rawRequests),
+				}
+
+				Expect(VmiInterfaceIPRequests(vmi, "podnet", primaryNet)).To(ConsistOf(expectedIPRequest))
+			},
+			Entry("expanded IPv6 address", "FD12:1234:0000:0000:0000:0000:0000:0200", "fd12:1234::200/64"),
+			Entry("IPv4-mapped IPv6 address", "::ffff:192.168.1.10", "192.168.1.10/16"),
+			Entry("IPv4 address in CIDR form", "192.168.1.10/16", "192.168.1.10/16"),
+			Entry("IPv6 address in CIDR form", "fd12:1234::200/64", "fd12:1234::200/64"),
+			Entry("IPv4-mapped IPv6 address in CIDR form", "::ffff:192.168.1.10/112", "192.168.1.10/16"),
+		)
+
+		It("should return error for a request in CIDR form whose prefix length does not match the subnet", func() {
+			ipRequests := map[string][]string{
+				"podnet": {"192.168.1.10/24"},
+			}
+			rawRequests, _ := json.Marshal(ipRequests)
+			vmi.Annotations = map[string]string{
+				config.IPRequestsAnnotation: string(rawRequests),
+			}
+
+			result, err := VmiInterfaceIPRequests(vmi, "podnet", primaryNet)
+			Expect(err).To(MatchError(ContainSubstring("prefix length does not match the network subnet 192.168.0.0/16")))
+			Expect(result).To(BeNil())
 		})
 	})
 })
@@ -0,0 +1,224 @@
+package ips
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+// InterfaceRequests holds the requests of a VM interface, i.e. its IPs and, when the interface should hold the
+// default route, the gateways to use. It is either set as an object, or as the list of the requested IPs.
+type InterfaceRequests struct {
+	IPs          []string `json:"ips,omitempty"`
+	Gateways     []string `json:"gateways,omitempty"`
+	DefaultRoute bool     `json:"defaultRoute,omitempty"`
+}
+
+func (r *InterfaceRequests) UnmarshalJSON(data []byte) error {
+	var ipRequests []string
+	if err := json.Unmarshal(data, &ipRequests); err == nil {
+		*r = InterfaceRequests{IPs: ipRequests}
+		return nil
+	}
+
+	type interfaceRequests InterfaceRequests
+	var requests interfaceRequests
+	if err := json.Unmarshal(data, &requests); err != nil {
+		return fmt.Errorf("interface requests must either be a list of IPs or an object: %w", err)
+	}
+	*r = InterfaceRequests(requests)
+	return nil
+}
+
+// ParseInterfaceRequests returns the requests of each VM interface, indexed by the interface name.
+func ParseInterfaceRequests(annotations map[string]string) (map[string]InterfaceRequests, error) {
+	rawRequests, hasRequests := annotations[config.IPRequestsAnnotation]
+	if !hasRequests {
+		return nil, nil
+	}
+
+	var requests map[string]InterfaceRequests
+	if err := json.Unmarshal([]byte(rawRequests), &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// ParseIPRequests returns the IP addresses requested for each VM interface,
+// indexed by the interface name.
+func ParseIPRequests(annotations map[string]string) (map[string][]string, error) {
+	requests, err := ParseInterfaceRequests(annotations)
+	if err != nil || requests == nil {
+		return nil, err
+	}
+
+	ipRequests := make(map[string][]string, len(requests))
+	for ifaceName, ifaceRequests := range requests {
+		ipRequests[ifaceName] = ifaceRequests.IPs
+	}
+	return ipRequests, nil
+}
+
+// ValidateIPRequests ensures the IPs requested for an interface can be served by the network:
+// at most one IP per family, each of them within one of the network subnets.
+func ValidateIPRequests(requestedIPs []string, netConfig *config.RelevantConfig) error {
+	if len(requestedIPs) == 0 {
+		return nil
+	}
+	if err := ensureIPRequestsSupported(netConfig); err != nil {
+		return err
+	}
+
+	subnets, err := parseNetworkSubnets(netConfig)
+	if err != nil {
+		return err
+	}
+
+	var hasIPv4Request, hasIPv6Request bool
+	for _, ipRequest := range requestedIPs {
+		ip, _, err := subnets.resolveIPRequest(ipRequest)
+		if err != nil {
+			return err
+		}
+		if ip.To4() != nil {
+			if hasIPv4Request {
+				return fmt.Errorf("more than one IPv4 IP requested: %v", requestedIPs)
+			}
+			hasIPv4Request = true
+		} else {
+			if hasIPv6Request {
+				return fmt.Errorf("more than one IPv6 IP requested: %v", requestedIPs)
+			}
+			hasIPv6Request = true
+		}
+	}
+
+	return nil
+}
+
+// networkSubnets holds the subnets of a network, along with the subnets excluded from its IP allocations and the
+// configured gateways
+type networkSubnets struct {
+	netConfig      *config.RelevantConfig
+	ipv4Subnets    []string
+	ipv6Subnets    []string
+	excludeSubnets []*net.IPNet
+	gateways       []net.IP
+}
+
+func parseNetworkSubnets(netConfig *config.RelevantConfig) (*networkSubnets, error) {
+	ipv4Subnets, ipv6Subnets, err := SeparateSubnetsByFamily(netConfig.Subnets)
+	if err != nil {
+		return nil, err
+	}
+	excludeSubnets, err := parseExcludeSubnets(netConfig.ExcludeSubnets)
+	if err != nil {
+		return nil, err
+	}
+	gateways, err := parseGateways(netConfig.Gateways)
+	if err != nil {
+		return nil, err
+	}
+	return &networkSubnets{
+		netConfig:      netConfig,
+		ipv4Subnets:    ipv4Subnets,
+		ipv6Subnets:    ipv6Subnets,
+		excludeSubnets: excludeSubnets,
+		gateways:       gateways,
+	}, nil
+}
+
+// resolveIPRequest canonicalizes the requested IP, i.e. strips the prefix length of the requests written in CIDR
+// form and unmaps the IPv4-mapped IPv6 addresses; it returns the IP along with the network subnet holding it.
+// IPs within the excluded subnets, or reserved in the subnet holding them, are rejected.
+func (n *networkSubnets) resolveIPRequest(ipRequest string) (net.IP, *net.IPNet, error) {
+	ip, subnet, err := resolveIPRequest(ipRequest, n.ipv4Subnets, n.ipv6Subnets)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, excludeSubnet := range n.excludeSubnets {
+		if excludeSubnet.Contains(ip) {
+			return nil, nil, fmt.Errorf("IP request %s is within the excluded subnet %s of the network",
+				ipRequest, excludeSubnet.String())
+		}
+	}
+	gateway := subnetGateway(n.netConfig, n.gateways, subnet)
+	if reservedAddress := reservedAddressKind(ip, subnet, gateway); reservedAddress != "" {
+		return nil, nil, fmt.Errorf("IP request %s is the reserved %s address of the network subnet %s",
+			ipRequest, reservedAddress, subnet.String())
+	}
+	return ip, subnet, nil
+}
+
+// resolveIPRequest canonicalizes the requested IP, i.e. strips the prefix length of the requests written in CIDR
+// form and unmaps the IPv4-mapped IPv6 addresses; it returns the IP along with the network subnet holding it.
+func resolveIPRequest(ipRequest string, ipv4Subnets, ipv6Subnets []string) (net.IP, *net.IPNet, error) {
+	ip, requestedPrefixLength, err := parseIPRequest(ipRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	family, familySubnets := "IPv6", ipv6Subnets
+	if ip.To4() != nil {
+		family, familySubnets = "IPv4", ipv4Subnets
+	}
+	if len(familySubnets) == 0 {
+		return nil, nil, fmt.Errorf("no %s subnet configured for %s IP request: %s", family, family, ipRequest)
+	}
+
+	for _, subnet := range familySubnets {
+		parsedSubnet, err := ParseSubnet(subnet)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid subnet format: %s", subnet)
+		}
+		if !parsedSubnet.CIDR.Contains(ip) {
+			continue
+		}
+		if prefixLength, _ := parsedSubnet.CIDR.Mask.Size(); requestedPrefixLength != -1 &&
+			requestedPrefixLength != prefixLength {
+			return nil, nil, fmt.Errorf("IP request %s prefix length does not match the network subnet %s",
+				ipRequest, subnet)
+		}
+		return ip, parsedSubnet.CIDR, nil
+	}
+	return nil, nil, fmt.Errorf("IP request %s is not within the network subnets %v", ipRequest, familySubnets)
+}
+
+// CanonicalIP returns the canonical textual form of the requested IP; e.g. both "::ffff:10.0.0.5" and "10.0.0.5/24"
+// requests are canonicalized as "10.0.0.5"
+func CanonicalIP(ipRequest string) (string, error) {
+	ip, _, err := parseIPRequest(ipRequest)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// parseIPRequest parses the requested IP, unmapping the IPv4-mapped IPv6 addresses; the prefix length of the
+// requests written in CIDR form is returned as well, -1 otherwise
+func parseIPRequest(ipRequest string) (net.IP, int, error) {
+	ip, prefixLength := net.ParseIP(ipRequest), -1
+	if strings.Contains(ipRequest, "/") {
+		cidrIP, cidr, err := net.ParseCIDR(ipRequest)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid IP address format: %s", ipRequest)
+		}
+		ip = cidrIP
+		prefixLength, _ = cidr.Mask.Size()
+	}
+	if ip == nil {
+		return nil, 0, fmt.Errorf("invalid IP address format: %s", ipRequest)
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		if len(ip) == net.IPv6len && prefixLength >= 96 {
+			// the prefix length of an IPv4-mapped IPv6 CIDR accounts for the ::ffff:0:0/96 prefix
+			prefixLength -= 96
+		}
+		ip = ipv4
+	}
+	return ip, prefixLength, nil
+}
@@ -0,0 +1,165 @@
+package ips
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+func TestIPs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IPs test suite")
+}
+
+var _ = Describe("ParseIPRequests", func() {
+	It("should return nil when the IP requests annotation is missing", func() {
+		Expect(ParseIPRequests(map[string]string{"foo": "bar"})).To(BeNil())
+	})
+
+	It("should return the requested IPs indexed by interface name", func() {
+		Expect(ParseIPRequests(map[string]string{
+			config.IPRequestsAnnotation: `{"podnet": ["192.168.1.10", "fd12:1234::200"], "othernet": ["10.0.0.1"]}`,
+		})).To(Equal(map[string][]string{
+			"podnet":   {"192.168.1.10", "fd12:1234::200"},
+			"othernet": {"10.0.0.1"},
+		}))
+	})
+
+	It("should fail for a malformed annotation", func() {
+		_, err := ParseIPRequests(map[string]string{config.IPRequestsAnnotation: `{"podnet": "192.168.1.10"}`})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseInterfaceRequests", func() {
+	It("should accept both the list of IPs and the object forms", func() {
+		Expect(ParseInterfaceRequests(map[string]string{
+			config.IPRequestsAnnotation: `{
+				"podnet": {"ips": ["192.168.1.10"], "gateways": ["192.168.0.1"], "defaultRoute": true},
+				"othernet": ["10.0.0.10"]
+			}`,
+		})).To(Equal(map[string]InterfaceRequests{
+			"podnet":   {IPs: []string{"192.168.1.10"}, Gateways: []string{"192.168.0.1"}, DefaultRoute: true},
+			"othernet": {IPs: []string{"10.0.0.10"}},
+		}))
+	})
+
+	It("should fail when the interface requests are neither a list of IPs nor an object", func() {
+		_, err := ParseInterfaceRequests(map[string]string{config.IPRequestsAnnotation: `{"podnet": true}`})
+		Expect(err).To(MatchError(ContainSubstring("interface requests must either be a list of IPs or an object")))
+	})
+})
+
+var _ = Describe("ValidateIPRequests", func() {
+	netConfig := &config.RelevantConfig{
+		Name:    "net",
+		Subnets: "192.168.0.0/16,10.0.0.0/8,fd12:1234::/64",
+	}
+
+	DescribeTable("accepts IP requests served by the network",
+		func(requestedIPs ...string) {
+			Expect(ValidateIPRequests(requestedIPs, netConfig)).To(Succeed())
+		},
+		Entry("no IPs"),
+		Entry("a single IPv4", "192.168.1.10"),
+		Entry("an IPv4 within the second subnet", "10.1.1.1"),
+		Entry("an IP per family", "192.168.1.10", "fd12:1234::200"),
+		Entry("an IP per family in CIDR form", "192.168.1.10/16", "fd12:1234::200/64"),
+		Entry("an IPv4-mapped IPv6 and an IPv6", "::ffff:192.168.1.10", "fd12:1234::200"),
+	)
+
+	DescribeTable("rejects IP requests not served by the network",
+		func(subnets string, requestedIPs []string, expectedErr string) {
+			Expect(ValidateIPRequests(requestedIPs, &config.RelevantConfig{Subnets: subnets})).To(
+				MatchError(ContainSubstring(expectedErr)),
+			)
+		},
+		Entry("invalid IP", "192.168.0.0/16", []string{"not.an.ip"}, "invalid IP address format"),
+		Entry("IP outside the subnets", "192.168.0.0/16", []string{"172.16.0.1"}, "is not within the network subnets"),
+		Entry("unserved IPv4 family", "fd12:1234::/64", []string{"192.168.1.10"}, "no IPv4 subnet configured"),
+		Entry("unserved IPv6 family", "192.168.0.0/16", []string{"fd12:1234::200"}, "no IPv6 subnet configured"),
+		Entry("network without subnets", "", []string{"192.168.1.10"}, "no IPv4 subnet configured"),
+		Entry("two IPv4s", "192.168.0.0/16", []string{"192.168.1.10", "192.168.1.11"}, "more than one IPv4 IP requested"),
+		Entry("two IPv6s", "fd12:1234::/64", []string{"fd12:1234::10", "fd12:1234::20"}, "more than one IPv6 IP requested"),
+		Entry("an IPv4 and an IPv4-mapped IPv6", "192.168.0.0/16", []string{"192.168.1.10", "::ffff:192.168.1.11"},
+			"more than one IPv4 IP requested"),
+		Entry("CIDR prefix length not matching the subnet", "192.168.0.0/16", []string{"192.168.1.10/24"},
+			"prefix length does not match the network subnet"),
+		Entry("IPv4 network address", "192.168.0.0/16", []string{"192.168.0.0"},
+			"IP request 192.168.0.0 is the reserved network address of the network subnet 192.168.0.0/16"),
+		Entry("IPv4 broadcast address", "192.168.0.0/16", []string{"192.168.255.255"},
+			"IP request 192.168.255.255 is the reserved broadcast address of the network subnet 192.168.0.0/16"),
+		Entry("IPv6 network address", "fd12:1234::/64", []string{"fd12:1234::"},
+			"IP request fd12:1234:: is the reserved network address of the network subnet fd12:1234::/64"),
+		Entry("invalid subnets", "192.168.0.0", []string{"192.168.1.10"}, "invalid subnet format"),
+		Entry("layer3 subnets", "192.168.0.0/16/24", []string{"192.168.1.10"},
+			"IP requests are not supported on the layer3 network"),
+	)
+
+	DescribeTable("rejects IP requests for the network gateways",
+		func(netConfig *config.RelevantConfig, requestedIP string, expectedErr string) {
+			Expect(ValidateIPRequests([]string{requestedIP}, netConfig)).To(MatchError(expectedErr))
+		},
+		Entry("IPv4 primary network gateway",
+			&config.RelevantConfig{Role: config.NetworkRolePrimary, Subnets: "192.168.0.0/16"}, "192.168.0.1",
+			"IP request 192.168.0.1 is the reserved gateway address of the network subnet 192.168.0.0/16"),
+		Entry("IPv6 primary network gateway",
+			&config.RelevantConfig{Role: config.NetworkRolePrimary, Subnets: "fd12:1234::/64"}, "fd12:1234::1",
+			"IP request fd12:1234::1 is the reserved gateway address of the network subnet fd12:1234::/64"),
+		Entry("IPv4 configured gateway",
+			&config.RelevantConfig{Subnets: "192.168.0.0/16", Gateways: "192.168.0.254"}, "192.168.0.254",
+			"IP request 192.168.0.254 is the reserved gateway address of the network subnet 192.168.0.0/16"),
+		Entry("IPv6 configured gateway",
+			&config.RelevantConfig{Subnets: "192.168.0.0/16,fd12:1234::/64", Gateways: "192.168.0.1,fd12:1234::fe"},
+			"fd12:1234::fe",
+			"IP request fd12:1234::fe is the reserved gateway address of the network subnet fd12:1234::/64"),
+		Entry("invalid configured gateway",
+			&config.RelevantConfig{Subnets: "192.168.0.0/16", Gateways: "192.168.0"}, "192.168.1.10",
+			"invalid gateway format: 192.168.0"),
+	)
+
+	DescribeTable("accepts the first host address of the subnets without gateway",
+		func(netConfig *config.RelevantConfig, requestedIP string) {
+			Expect(ValidateIPRequests([]string{requestedIP}, netConfig)).To(Succeed())
+		},
+		Entry("IPv4 secondary network", &config.RelevantConfig{Subnets: "192.168.0.0/16"}, "192.168.0.1"),
+		Entry("IPv6 secondary network", &config.RelevantConfig{Subnets: "fd12:1234::/64"}, "fd12:1234::1"),
+		Entry("network with a gateway set elsewhere",
+			&config.RelevantConfig{Subnets: "192.168.0.0/16", Gateways: "192.168.0.254"}, "192.168.0.1"),
+	)
+
+	DescribeTable("rejects IP requests within the network excluded subnets",
+		func(excludeSubnets string, requestedIP string, expectedErr string) {
+			netConfig := &config.RelevantConfig{Subnets: "192.168.0.0/16,fd12:1234::/64", ExcludeSubnets: excludeSubnets}
+			Expect(ValidateIPRequests([]string{requestedIP}, netConfig)).To(MatchError(expectedErr))
+		},
+		Entry("IPv4 excluded subnet", "192.168.1.0/24", "192.168.1.10",
+			"IP request 192.168.1.10 is within the excluded subnet 192.168.1.0/24 of the network"),
+		Entry("IPv6 excluded subnet", "192.168.1.0/24,fd12:1234::/120", "fd12:1234::20",
+			"IP request fd12:1234::20 is within the excluded subnet fd12:1234::/120 of the network"),
+		Entry("excluded single address", "192.168.1.10", "192.168.1.10",
+			"IP request 192.168.1.10 is within the excluded subnet 192.168.1.10/32 of the network"),
+		Entry("invalid excluded subnets", "192.168.1.0/33", "192.168.1.10",
+			"invalid excluded subnet format: 192.168.1.0/33"),
+	)
+
+	DescribeTable("accepts the first and last addresses of the subnets without reserved addresses",
+		func(subnets string, requestedIP string) {
+			Expect(ValidateIPRequests([]string{requestedIP}, &config.RelevantConfig{Subnets: subnets})).To(Succeed())
+		},
+		Entry("IPv4 point-to-point subnet", "192.168.1.0/31", "192.168.1.0"),
+		Entry("IPv4 single address subnet", "192.168.1.1/32", "192.168.1.1"),
+		Entry("IPv6 point-to-point subnet", "fd12:1234::/127", "fd12:1234::1"),
+		Entry("IPv6 single address subnet", "fd12:1234::/128", "fd12:1234::"),
+		Entry("IPv6 last address", "fd12:1234::/64", "fd12:1234::ffff:ffff:ffff:ffff"),
+	)
+
+	It("rejects IP requests on a layer3 network whatever its subnets format", func() {
+		layer3Config := &config.RelevantConfig{Name: "net", Topology: config.TopologyLayer3, Subnets: "192.168.0.0/16"}
+		Expect(ValidateIPRequests([]string{"192.168.1.10"}, layer3Config)).To(MatchError(ErrUnsupportedIPRequests))
+		Expect(ValidateIPRequests(nil, layer3Config)).To(Succeed())
+	})
+})
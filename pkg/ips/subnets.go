@@ -0,0 +1,175 @@
+package ips
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+// ErrUnsupportedIPRequests reports the networks which cannot honor IP requests, whatever the requested IPs
+var ErrUnsupportedIPRequests = errors.New("IP requests are not supported")
+
+// Subnet is a network subnet; the layer3 subnets, formatted as <cidr>/<host prefix>, additionally carry the
+// prefix length of the host subnets each node is assigned out of the subnet
+type Subnet struct {
+	CIDR       *net.IPNet
+	HostPrefix int
+}
+
+// ParseSubnet parses both the <cidr> and the layer3 <cidr>/<host prefix> subnet formats
+func ParseSubnet(subnet string) (*Subnet, error) {
+	cidr, hostPrefix := subnet, 0
+	if strings.Count(subnet, "/") == 2 {
+		separatorIndex := strings.LastIndex(subnet, "/")
+		var err error
+		if hostPrefix, err = strconv.Atoi(subnet[separatorIndex+1:]); err != nil {
+			return nil, fmt.Errorf("invalid host prefix in subnet %s: %w", subnet, err)
+		}
+		cidr = subnet[:separatorIndex]
+	}
+
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if hostPrefix != 0 {
+		prefix, bits := ipNet.Mask.Size()
+		if hostPrefix < prefix || hostPrefix > bits {
+			return nil, fmt.Errorf("host prefix %d of subnet %s is not within [%d, %d]", hostPrefix, subnet, prefix, bits)
+		}
+	}
+	return &Subnet{CIDR: ipNet, HostPrefix: hostPrefix}, nil
+}
+
+func (s *Subnet) String() string {
+	if s.HostPrefix == 0 {
+		return s.CIDR.String()
+	}
+	return fmt.Sprintf("%s/%d", s.CIDR.String(), s.HostPrefix)
+}
+
+// parseExcludeSubnets parses the comma separated excluded subnets; single addresses are accepted as well
+func parseExcludeSubnets(excludeSubnets string) ([]*net.IPNet, error) {
+	var parsedSubnets []*net.IPNet
+	for _, excludeSubnet := range strings.Split(excludeSubnets, ",") {
+		excludeSubnet = strings.TrimSpace(excludeSubnet)
+		if excludeSubnet == "" {
+			continue
+		}
+		if ip := net.ParseIP(excludeSubnet); ip != nil {
+			parsedSubnets = append(parsedSubnets, singleAddressSubnet(ip))
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(excludeSubnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded subnet format: %s", excludeSubnet)
+		}
+		parsedSubnets = append(parsedSubnets, ipNet)
+	}
+	return parsedSubnets, nil
+}
+
+func singleAddressSubnet(ip net.IP) *net.IPNet {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}
+}
+
+// reservedAddressKind returns the kind of the subnet reserved address the IP is, i.e. the network address, the
+// subnet gateway address when it has one or, for IPv4, the broadcast address; the point-to-point (/31, /127) and
+// single address subnets do not reserve any address but their configured gateway.
+func reservedAddressKind(ip net.IP, subnet *net.IPNet, gateway net.IP) string {
+	if gateway != nil && ip.Equal(gateway) {
+		return "gateway"
+	}
+	prefixLength, bits := subnet.Mask.Size()
+	if bits-prefixLength <= 1 {
+		return ""
+	}
+
+	networkIP := subnet.IP.Mask(subnet.Mask)
+	if ip.Equal(networkIP) {
+		return "network"
+	}
+	if bits == 8*net.IPv4len && ip.Equal(broadcastIP(subnet)) {
+		return "broadcast"
+	}
+	return ""
+}
+
+// subnetGateway returns the gateway address of the network subnet: the gateway set in the network IPAM
+// configuration or, on the OVN-Kubernetes primary networks, the first host address, which their gateway router
+// holds; nil when the subnet has no gateway, e.g. on the secondary layer2 and localnet networks.
+func subnetGateway(netConfig *config.RelevantConfig, gateways []net.IP, subnet *net.IPNet) net.IP {
+	for _, gateway := range gateways {
+		if subnet.Contains(gateway) {
+			return gateway
+		}
+	}
+	if netConfig.Role == config.NetworkRolePrimary {
+		return nextIP(subnet.IP.Mask(subnet.Mask))
+	}
+	return nil
+}
+
+// parseGateways parses the comma separated gateway addresses
+func parseGateways(gateways string) ([]net.IP, error) {
+	var parsedGateways []net.IP
+	for _, gateway := range strings.Split(gateways, ",") {
+		gateway = strings.TrimSpace(gateway)
+		if gateway == "" {
+			continue
+		}
+		ip := net.ParseIP(gateway)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid gateway format: %s", gateway)
+		}
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+		parsedGateways = append(parsedGateways, ip)
+	}
+	return parsedGateways, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func broadcastIP(subnet *net.IPNet) net.IP {
+	networkIP := subnet.IP.Mask(subnet.Mask)
+	broadcast := make(net.IP, len(networkIP))
+	for i := range networkIP {
+		broadcast[i] = networkIP[i] | ^subnet.Mask[i]
+	}
+	return broadcast
+}
+
+// ensureIPRequestsSupported rejects the layer3 networks: their subnets are split into per node host subnets, hence
+// the IP of a VM would depend on the node it runs on, and would not survive its migration.
+func ensureIPRequestsSupported(netConfig *config.RelevantConfig) error {
+	isLayer3 := netConfig.Topology == config.TopologyLayer3
+	for _, subnet := range strings.Split(netConfig.Subnets, ",") {
+		if parsedSubnet, err := ParseSubnet(strings.TrimSpace(subnet)); err == nil && parsedSubnet.HostPrefix != 0 {
+			isLayer3 = true
+		}
+	}
+	if isLayer3 {
+		return fmt.Errorf("%w on the layer3 network %q: its subnets are split into per node host subnets, "+
+			"hence the VM IPs would depend on the node the VM runs on", ErrUnsupportedIPRequests, netConfig.Name)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package ips
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseSubnet", func() {
+	DescribeTable("parses the subnet and its host prefix",
+		func(subnet, expectedCIDR string, expectedHostPrefix int) {
+			parsedSubnet, err := ParseSubnet(subnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsedSubnet.CIDR.String()).To(Equal(expectedCIDR))
+			Expect(parsedSubnet.HostPrefix).To(Equal(expectedHostPrefix))
+			Expect(parsedSubnet.String()).To(Equal(subnet))
+		},
+		Entry("IPv4 subnet", "10.128.0.0/16", "10.128.0.0/16", 0),
+		Entry("IPv6 subnet", "fd12:1234::/64", "fd12:1234::/64", 0),
+		Entry("IPv4 layer3 subnet", "10.128.0.0/16/24", "10.128.0.0/16", 24),
+		Entry("IPv6 layer3 subnet", "fd12:1234::/56/64", "fd12:1234::/56", 64),
+	)
+
+	DescribeTable("rejects invalid subnets",
+		func(subnet string) {
+			_, err := ParseSubnet(subnet)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("without prefix", "10.128.0.0"),
+		Entry("non numeric host prefix", "10.128.0.0/16/abc"),
+		Entry("host prefix shorter than the subnet prefix", "10.128.0.0/16/8"),
+		Entry("host prefix longer than the address", "10.128.0.0/16/33"),
+		Entry("too many prefixes", "10.128.0.0/16/24/28"),
+	)
+})
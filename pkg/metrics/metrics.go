@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "kubevirt_ipam_controller"
+
+var invalidNADConfigs = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invalid_nad_configs_total",
+		Help: "Number of NAD configurations (one per NAD resourceVersion) which could not be parsed; the count " +
+			"of a NAD is dropped once it is deleted",
+	},
+	[]string{"namespace", "name"},
+)
+
+var invalidUserDefinedNetworks = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invalid_user_defined_networks_total",
+		Help: "Number of UserDefinedNetwork and ClusterUserDefinedNetwork specs (one per resourceVersion) which " +
+			"could not be read, by kind and namespace (empty for the cluster scoped networks)",
+	},
+	[]string{"kind", "namespace"},
+)
+
+var vmiResolutionFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vmi_resolution_failures_total",
+		Help: "Number of launcher pods whose VMI could not be resolved on admission, by the policy applied " +
+			"(strict pods are rejected, best-effort pods are admitted without mutation)",
+	},
+	[]string{"namespace", "policy"},
+)
+
+var vmiCacheMisses = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vmi_cache_misses_total",
+		Help: "Number of launcher pod admissions whose VMI was missing from the informer cache, hence read " +
+			"from the API server",
+	},
+	[]string{"namespace"},
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(invalidNADConfigs, invalidUserDefinedNetworks, vmiResolutionFailures, vmiCacheMisses)
+}
+
+// ReportInvalidNADConfig accounts for a NAD whose configuration could not be parsed
+func ReportInvalidNADConfig(nadNamespace, nadName string) {
+	invalidNADConfigs.WithLabelValues(nadNamespace, nadName).Inc()
+}
+
+// ForgetInvalidNADConfig drops the invalid configurations count of a deleted NAD
+func ForgetInvalidNADConfig(nadNamespace, nadName string) {
+	invalidNADConfigs.DeleteLabelValues(nadNamespace, nadName)
+}
+
+// ReportInvalidUserDefinedNetwork accounts for a user defined network whose spec could not be read
+func ReportInvalidUserDefinedNetwork(kind, networkNamespace string) {
+	invalidUserDefinedNetworks.WithLabelValues(kind, networkNamespace).Inc()
+}
+
+// ReportVMIResolutionFailure accounts for a launcher pod whose VMI could not be resolved on admission
+func ReportVMIResolutionFailure(podNamespace, policy string) {
+	vmiResolutionFailures.WithLabelValues(podNamespace, policy).Inc()
+}
+
+// ReportVMICacheMiss accounts for a launcher pod admission whose VMI was read from the API server after a cache miss
+func ReportVMICacheMiss(vmiNamespace string) {
+	vmiCacheMisses.WithLabelValues(vmiNamespace).Inc()
+}
@@ -0,0 +1,164 @@
+package nads
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/metrics"
+)
+
+// ConfigCache holds the relevant configuration of the NADs, parsed once per NAD resourceVersion
+type ConfigCache struct {
+	lock                sync.RWMutex
+	configs             map[types.NamespacedName]cachedConfig
+	interpreters        *config.InterpreterRegistry
+	reportInvalidConfig InvalidConfigReporter
+}
+
+// InvalidConfigReporter is notified once per NAD resourceVersion whose configuration cannot be parsed, by the NAD
+// informer event handler
+type InvalidConfigReporter func(nad *v1.NetworkAttachmentDefinition, err error)
+
+type ConfigCacheOption func(*ConfigCache)
+
+type cachedConfig struct {
+	resourceVersion string
+	rawConfig       string
+	config          *config.RelevantConfig
+	err             error
+	reported        bool
+}
+
+func NewConfigCache(opts ...ConfigCacheOption) *ConfigCache {
+	configCache := &ConfigCache{
+		configs:      map[types.NamespacedName]cachedConfig{},
+		interpreters: config.NewInterpreterRegistry(),
+	}
+	for _, opt := range opts {
+		opt(configCache)
+	}
+	return configCache
+}
+
+// WithInterpreters sets the registry interpreting the NAD configurations, according to their CNI plugin type
+func WithInterpreters(interpreters *config.InterpreterRegistry) ConfigCacheOption {
+	return func(configCache *ConfigCache) {
+		configCache.interpreters = interpreters
+	}
+}
+
+func WithInvalidConfigReporter(reporter InvalidConfigReporter) ConfigCacheOption {
+	return func(configCache *ConfigCache) {
+		configCache.reportInvalidConfig = reporter
+	}
+}
+
+// Config returns the relevant configuration of the NAD; the returned configuration is shared and must not be modified.
+// It has no side effect besides caching the parsed configuration, hence can be used by the NAD indexers.
+func (c *ConfigCache) Config(nad *v1.NetworkAttachmentDefinition) (*config.RelevantConfig, error) {
+	nadKey := types.NamespacedName{Namespace: nad.Namespace, Name: nad.Name}
+
+	c.lock.RLock()
+	entry, isCached := c.configs[nadKey]
+	c.lock.RUnlock()
+	if isCached && entry.isFor(nad) {
+		return entry.config, entry.err
+	}
+
+	netConfig, err := c.interpreters.Config(nad.Spec.Config)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, isCached := c.configs[nadKey]; isCached && entry.isFor(nad) {
+		// parsed meanwhile by a concurrent caller
+		return entry.config, entry.err
+	}
+	c.configs[nadKey] = cachedConfig{
+		resourceVersion: nad.ResourceVersion,
+		rawConfig:       nad.Spec.Config,
+		config:          netConfig,
+		err:             err,
+	}
+	return netConfig, err
+}
+
+// reportIfInvalid notifies the invalid config reporter when the NAD configuration cannot be parsed, unless already
+// done for the NAD resourceVersion; e.g. on the informer resyncs
+func (c *ConfigCache) reportIfInvalid(nad *v1.NetworkAttachmentDefinition) {
+	if _, err := c.Config(nad); err == nil || c.reportInvalidConfig == nil {
+		return
+	}
+
+	nadKey := types.NamespacedName{Namespace: nad.Namespace, Name: nad.Name}
+	c.lock.Lock()
+	entry, isCached := c.configs[nadKey]
+	if !isCached || !entry.isFor(nad) || entry.reported {
+		c.lock.Unlock()
+		return
+	}
+	entry.reported = true
+	c.configs[nadKey] = entry
+	c.lock.Unlock()
+
+	c.reportInvalidConfig(nad, entry.err)
+}
+
+// Forget drops the configuration of the NAD out of the cache
+func (c *ConfigCache) Forget(nadKey types.NamespacedName) {
+	c.lock.Lock()
+	delete(c.configs, nadKey)
+	c.lock.Unlock()
+}
+
+// EventHandler reports the NADs whose configuration cannot be parsed as they are added or updated, and forgets the
+// configuration of the NADs once they are deleted, along with their invalid configurations count; the cache (and
+// the metric) would otherwise hold every NAD ever seen
+func (c *ConfigCache) EventHandler() toolscache.ResourceEventHandler {
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if nad, isNAD := obj.(*v1.NetworkAttachmentDefinition); isNAD {
+				c.reportIfInvalid(nad)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if nad, isNAD := newObj.(*v1.NetworkAttachmentDefinition); isNAD {
+				c.reportIfInvalid(nad)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, isTombstone := obj.(toolscache.DeletedFinalStateUnknown); isTombstone {
+				obj = tombstone.Obj
+			}
+			if nad, isNAD := obj.(*v1.NetworkAttachmentDefinition); isNAD {
+				c.Forget(types.NamespacedName{Namespace: nad.Namespace, Name: nad.Name})
+				metrics.ForgetInvalidNADConfig(nad.Namespace, nad.Name)
+			}
+		},
+	}
+}
+
+// SetupEventHandler registers the config cache event handler on the NAD informer
+func SetupEventHandler(ctx context.Context, informers cache.Informers, configs *ConfigCache) error {
+	informer, err := informers.GetInformer(ctx, &v1.NetworkAttachmentDefinition{})
+	if err != nil {
+		return fmt.Errorf("failed to get the NAD informer: %w", err)
+	}
+	if _, err := informer.AddEventHandler(configs.EventHandler()); err != nil {
+		return fmt.Errorf("failed to watch the NADs: %w", err)
+	}
+	return nil
+}
+
+// the raw config is compared as well since objects not coming from the API server may lack a resourceVersion
+func (e cachedConfig) isFor(nad *v1.NetworkAttachmentDefinition) bool {
+	return e.resourceVersion == nad.ResourceVersion && e.rawConfig == nad.Spec.Config
+}
@@ -0,0 +1,174 @@
+package nads_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+)
+
+func TestNADs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "NADs test suite")
+}
+
+var _ = Describe("NAD config cache", func() {
+	var nadConfigs *nads.ConfigCache
+
+	BeforeEach(func() {
+		nadConfigs = nads.NewConfigCache()
+	})
+
+	It("parses the NAD relevant configuration", func() {
+		Expect(nadConfigs.Config(nad("1", `{"name": "net1", "role": "primary", "allowPersistentIPs": true}`))).To(
+			Equal(&config.RelevantConfig{Name: "net1", Role: config.NetworkRolePrimary, AllowPersistentIPs: true}),
+		)
+	})
+
+	It("parses the NAD configuration with the provided interpreters", func() {
+		interpreters := config.NewInterpreterRegistry()
+		interpreters.Register("custom-cni", func([]byte) (*config.RelevantConfig, error) {
+			return &config.RelevantConfig{Name: "custom", AllowPersistentIPs: true}, nil
+		})
+		nadConfigs = nads.NewConfigCache(nads.WithInterpreters(interpreters))
+
+		Expect(nadConfigs.Config(nad("1", `{"name": "net1", "type": "custom-cni"}`))).To(
+			Equal(&config.RelevantConfig{Name: "custom", AllowPersistentIPs: true}),
+		)
+	})
+
+	It("parses the NAD configuration once per resourceVersion", func() {
+		firstConfig, err := nadConfigs.Config(nad("1", `{"name": "net1"}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(nadConfigs.Config(nad("1", `{"name": "net1"}`))).To(BeIdenticalTo(firstConfig))
+		Expect(nadConfigs.Config(nad("2", `{"name": "net1"}`))).NotTo(BeIdenticalTo(firstConfig))
+	})
+
+	It("parses the NAD configuration again when it changes", func() {
+		Expect(nadConfigs.Config(nad("1", `{"name": "net1"}`))).To(HaveField("Name", "net1"))
+		Expect(nadConfigs.Config(nad("1", `{"name": "net2"}`))).To(HaveField("Name", "net2"))
+	})
+
+	It("reports invalid NAD configurations", func() {
+		_, err := nadConfigs.Config(nad("1", `{"name": "net1",`))
+		Expect(err).To(MatchError(ContainSubstring("failed to extract CNI configuration from NAD")))
+	})
+
+	It("notifies the invalid NAD configurations once per resourceVersion from the NAD event handler", func() {
+		var reportedNADs []string
+		nadConfigs = nads.NewConfigCache(nads.WithInvalidConfigReporter(
+			func(nad *v1.NetworkAttachmentDefinition, _ error) {
+				reportedNADs = append(reportedNADs, nad.Name+"@"+nad.ResourceVersion)
+			},
+		))
+
+		eventHandler := nadConfigs.EventHandler()
+		eventHandler.OnAdd(nad("1", `{"name": "net1",`), false)
+		eventHandler.OnUpdate(nad("1", `{"name": "net1",`), nad("1", `{"name": "net1",`))
+		eventHandler.OnUpdate(nad("1", `{"name": "net1",`), nad("2", `{"name": "net1",`))
+		eventHandler.OnUpdate(nad("2", `{"name": "net1",`), nad("3", `{"name": "net1"}`))
+
+		Expect(reportedNADs).To(Equal([]string{"nad1@1", "nad1@2"}))
+	})
+
+	It("does not notify the invalid NAD configurations looked up, e.g. by the NAD indexers", func() {
+		var reportedNADs []string
+		nadConfigs = nads.NewConfigCache(nads.WithInvalidConfigReporter(
+			func(nad *v1.NetworkAttachmentDefinition, _ error) {
+				reportedNADs = append(reportedNADs, nad.Name+"@"+nad.ResourceVersion)
+			},
+		))
+
+		_, err := nadConfigs.Config(nad("1", `{"name": "net1",`))
+		Expect(err).To(HaveOccurred())
+		for _, extractValue := range nadConfigs.Indexers() {
+			extractValue(nad("1", `{"name": "net1",`))
+		}
+		Expect(reportedNADs).To(BeEmpty())
+
+		nadConfigs.EventHandler().OnAdd(nad("1", `{"name": "net1",`), false)
+		Expect(reportedNADs).To(Equal([]string{"nad1@1"}))
+	})
+
+	It("parses the NAD configuration again once the NAD is deleted and recreated", func() {
+		firstConfig, err := nadConfigs.Config(nad("1", `{"name": "net1"}`))
+		Expect(err).NotTo(HaveOccurred())
+		nadConfigs.EventHandler().OnDelete(nad("1", `{"name": "net1"}`))
+
+		Expect(nadConfigs.Config(nad("1", `{"name": "net1"}`))).NotTo(BeIdenticalTo(firstConfig))
+	})
+
+	It("drops the invalid configurations count of the deleted NADs", func() {
+		nadConfigs = nads.NewConfigCache(nads.WithInvalidConfigReporter(
+			nads.NewInvalidConfigEventReporter(record.NewFakeRecorder(10)),
+		))
+
+		invalidNAD := nad("1", `{"name": "net1",`)
+		nadConfigs.EventHandler().OnAdd(invalidNAD, false)
+		Expect(invalidNADConfigsSeries()).To(Equal(1))
+
+		nadConfigs.EventHandler().OnDelete(toolscache.DeletedFinalStateUnknown{Obj: invalidNAD})
+		Expect(invalidNADConfigsSeries()).To(BeZero())
+	})
+
+	DescribeTable("indexes the NAD configuration fields",
+		func(rawConfig string, expectedIndexes map[string][]string) {
+			indexes := map[string][]string{}
+			for indexName, extractValue := range nadConfigs.Indexers() {
+				if values := extractValue(nad("1", rawConfig)); values != nil {
+					indexes[indexName] = values
+				}
+			}
+			Expect(indexes).To(Equal(expectedIndexes))
+		},
+		Entry("primary network with persistent IPs", `{"name": "net1", "role": "primary", "allowPersistentIPs": true}`,
+			map[string][]string{
+				nads.RoleIndex:               {"primary"},
+				nads.NetworkNameIndex:        {"net1"},
+				nads.AllowPersistentIPsIndex: {"true"},
+			},
+		),
+		Entry("secondary network without persistent IPs", `{"name": "net1"}`,
+			map[string][]string{
+				nads.NetworkNameIndex:        {"net1"},
+				nads.AllowPersistentIPsIndex: {"false"},
+			},
+		),
+		Entry("invalid configuration", `{"name": "net1",`, map[string][]string{nads.InvalidConfigIndex: {"true"}}),
+	)
+})
+
+// invalidNADConfigsSeries returns the number of NADs accounted for by the invalid configurations metric
+func invalidNADConfigsSeries() int {
+	metricFamilies, err := ctrlmetrics.Registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() == "kubevirt_ipam_controller_invalid_nad_configs_total" {
+			return len(metricFamily.GetMetric())
+		}
+	}
+	return 0
+}
+
+func nad(resourceVersion string, rawConfig string) *v1.NetworkAttachmentDefinition {
+	return &v1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns1",
+			Name:            "nad1",
+			ResourceVersion: resourceVersion,
+		},
+		Spec: v1.NetworkAttachmentDefinitionSpec{Config: rawConfig},
+	}
+}
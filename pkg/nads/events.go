@@ -0,0 +1,21 @@
+package nads
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/metrics"
+)
+
+const InvalidConfigReason = "InvalidNetworkConfiguration"
+
+// NewInvalidConfigEventReporter reports the NADs with an invalid configuration via a warning Event and a metric
+func NewInvalidConfigEventReporter(recorder record.EventRecorder) InvalidConfigReporter {
+	return func(nad *v1.NetworkAttachmentDefinition, err error) {
+		recorder.Eventf(nad, corev1.EventTypeWarning, InvalidConfigReason,
+			"the NAD is ignored since its configuration cannot be parsed: %v", err)
+		metrics.ReportInvalidNADConfig(nad.Namespace, nad.Name)
+	}
+}
@@ -0,0 +1,75 @@
+package nads
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+// NAD field indexes, computed from the NAD relevant configuration; NADs with an invalid configuration are only indexed
+// by the InvalidConfigIndex
+const (
+	RoleIndex               = "nad.config.role"
+	NetworkNameIndex        = "nad.config.name"
+	AllowPersistentIPsIndex = "nad.config.allowPersistentIPs"
+	// InvalidConfigIndex indexes the NADs whose configuration cannot be parsed, under the "true" value
+	InvalidConfigIndex = "nad.config.invalid"
+)
+
+// Indexers returns the NAD field indexers, keyed by the index name
+func (c *ConfigCache) Indexers() map[string]client.IndexerFunc {
+	return map[string]client.IndexerFunc{
+		RoleIndex: c.indexerFor(func(netConfig *config.RelevantConfig) string {
+			return string(netConfig.Role)
+		}),
+		NetworkNameIndex: c.indexerFor(func(netConfig *config.RelevantConfig) string {
+			return netConfig.Name
+		}),
+		AllowPersistentIPsIndex: c.indexerFor(func(netConfig *config.RelevantConfig) string {
+			return strconv.FormatBool(netConfig.AllowPersistentIPs)
+		}),
+		InvalidConfigIndex: func(obj client.Object) []string {
+			nad, isNAD := obj.(*v1.NetworkAttachmentDefinition)
+			if !isNAD {
+				return nil
+			}
+			if _, err := c.Config(nad); err != nil {
+				return []string{"true"}
+			}
+			return nil
+		},
+	}
+}
+
+func (c *ConfigCache) indexerFor(field func(*config.RelevantConfig) string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		nad, isNAD := obj.(*v1.NetworkAttachmentDefinition)
+		if !isNAD {
+			return nil
+		}
+		netConfig, err := c.Config(nad)
+		if err != nil {
+			return nil
+		}
+		if value := field(netConfig); value != "" {
+			return []string{value}
+		}
+		return nil
+	}
+}
+
+// SetupIndexes registers the NAD field indexes on the provided indexer
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer, configs *ConfigCache) error {
+	for indexName, extractValue := range configs.Indexers() {
+		if err := indexer.IndexField(ctx, &v1.NetworkAttachmentDefinition{}, indexName, extractValue); err != nil {
+			return fmt.Errorf("failed to register the NAD %q index: %w", indexName, err)
+		}
+	}
+	return nil
+}
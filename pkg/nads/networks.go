@@ -0,0 +1,37 @@
+package nads
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+)
+
+// MultusNetworkNADKey returns the NAD key of a multus network; the network name is formatted in <ns>/<name> or
+// <name> format
+func MultusNetworkNADKey(namespace, networkName string) types.NamespacedName {
+	if namespaceAndName := strings.SplitN(networkName, "/", 2); len(namespaceAndName) == 2 {
+		return types.NamespacedName{Namespace: namespaceAndName[0], Name: namespaceAndName[1]}
+	}
+	return types.NamespacedName{Namespace: namespace, Name: networkName}
+}
+
+// FindNetworkNADs returns the keys of the NADs attaching to the network, whatever their namespace; it requires the
+// NetworkNameIndex to be registered.
+func FindNetworkNADs(ctx context.Context, cli client.Reader, networkName string) ([]types.NamespacedName, error) {
+	nadList := v1.NetworkAttachmentDefinitionList{}
+	if err := cli.List(ctx, &nadList, client.MatchingFields{NetworkNameIndex: networkName}); err != nil {
+		return nil, fmt.Errorf("failed listing the nads of network %q: %w", networkName, err)
+	}
+
+	nadKeys := make([]types.NamespacedName, 0, len(nadList.Items))
+	for _, nad := range nadList.Items {
+		nadKeys = append(nadKeys, types.NamespacedName{Namespace: nad.Namespace, Name: nad.Name})
+	}
+	return nadKeys, nil
+}
@@ -0,0 +1,89 @@
+package udn
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kubevirt/ipam-extensions/pkg/metrics"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+)
+
+// InvalidNetworkReporter is notified once per user defined network resourceVersion whose spec cannot be read
+type InvalidNetworkReporter func(network *unstructured.Unstructured, err error)
+
+// NewInvalidNetworkEventReporter reports the user defined networks whose spec cannot be read via a warning Event and
+// a metric
+func NewInvalidNetworkEventReporter(recorder record.EventRecorder) InvalidNetworkReporter {
+	return func(network *unstructured.Unstructured, err error) {
+		recorder.Eventf(network, corev1.EventTypeWarning, nads.InvalidConfigReason,
+			"the %s is ignored since its spec cannot be read: %v", network.GetKind(), err)
+		metrics.ReportInvalidUserDefinedNetwork(network.GetKind(), network.GetNamespace())
+	}
+}
+
+type invalidNetworkKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func invalidNetworkKeyOf(network *unstructured.Unstructured) invalidNetworkKey {
+	return invalidNetworkKey{kind: network.GetKind(), namespace: network.GetNamespace(), name: network.GetName()}
+}
+
+// invalidNetworkTracker reports the user defined networks whose spec cannot be read once per resourceVersion, as
+// they are read on every primary network lookup
+type invalidNetworkTracker struct {
+	lock     sync.Mutex
+	reported map[invalidNetworkKey]string
+	report   InvalidNetworkReporter
+}
+
+func newInvalidNetworkTracker() *invalidNetworkTracker {
+	return &invalidNetworkTracker{reported: map[invalidNetworkKey]string{}}
+}
+
+func (t *invalidNetworkTracker) reportOnce(ctx context.Context, network *unstructured.Unstructured, err error) {
+	key := invalidNetworkKeyOf(network)
+	t.lock.Lock()
+	reportedResourceVersion, isReported := t.reported[key]
+	t.reported[key] = network.GetResourceVersion()
+	t.lock.Unlock()
+	if isReported && reportedResourceVersion == network.GetResourceVersion() {
+		return
+	}
+
+	logf.FromContext(ctx).Info(
+		"ignoring the user defined network since its spec cannot be read",
+		"kind", key.kind,
+		"namespace", key.namespace,
+		"name", key.name,
+		"reason", err.Error(),
+	)
+	if t.report != nil {
+		t.report(network, err)
+	}
+}
+
+// forgetFixed forgets the reported networks of the kind (and namespace) which are no longer invalid, e.g. fixed or
+// deleted; invalidNetworks are all the invalid networks of the kind (and namespace)
+func (t *invalidNetworkTracker) forgetFixed(kind, namespace string, invalidNetworks []*unstructured.Unstructured) {
+	stillInvalid := map[invalidNetworkKey]bool{}
+	for _, network := range invalidNetworks {
+		stillInvalid[invalidNetworkKeyOf(network)] = true
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for key := range t.reported {
+		if key.kind == kind && key.namespace == namespace && !stillInvalid[key] {
+			delete(t.reported, key)
+		}
+	}
+}
@@ -2,32 +2,149 @@ package udn
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 
 	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
 )
 
+// ErrAmbiguousPrimaryNetwork is returned when several networks are the primary network of the same namespace
+var ErrAmbiguousPrimaryNetwork = errors.New("ambiguous primary network")
+
+// ErrInvalidUserDefinedNetwork is returned when the namespace primary network may be a user defined network whose
+// spec cannot be read
+var ErrInvalidUserDefinedNetwork = errors.New("invalid user defined network")
+
+// IsPrimaryNetworkError reports whether the primary network lookup failure only concerns the VMs attached to the
+// namespace primary network, e.g. the primary network is ambiguous or cannot be read
+func IsPrimaryNetworkError(err error) bool {
+	return errors.Is(err, ErrAmbiguousPrimaryNetwork) || errors.Is(err, ErrInvalidUserDefinedNetwork)
+}
+
+// PrimaryNetworkFinder looks up the configuration of the namespaces primary network
+type PrimaryNetworkFinder struct {
+	nadConfigs          *nads.ConfigCache
+	userDefinedNetworks bool
+	invalidNetworks     *invalidNetworkTracker
+}
+
+type FinderOption func(*PrimaryNetworkFinder)
+
+func NewPrimaryNetworkFinder(nadConfigs *nads.ConfigCache, opts ...FinderOption) *PrimaryNetworkFinder {
+	finder := &PrimaryNetworkFinder{nadConfigs: nadConfigs, invalidNetworks: newInvalidNetworkTracker()}
+	for _, opt := range opts {
+		opt(finder)
+	}
+	return finder
+}
+
+// WithUserDefinedNetworks reads the primary network configuration out of the OVN-Kubernetes UserDefinedNetwork
+// and ClusterUserDefinedNetwork objects, falling back to the NADs when those are not installed
+func WithUserDefinedNetworks() FinderOption {
+	return func(finder *PrimaryNetworkFinder) {
+		finder.userDefinedNetworks = true
+	}
+}
+
+// WithInvalidNetworkReporter sets the reporter of the user defined networks whose spec cannot be read
+func WithInvalidNetworkReporter(reporter InvalidNetworkReporter) FinderOption {
+	return func(finder *PrimaryNetworkFinder) {
+		finder.invalidNetworks.report = reporter
+	}
+}
+
+// FindPrimaryNetworkConfig returns the namespace primary network configuration; nil when there is none
+func (f *PrimaryNetworkFinder) FindPrimaryNetworkConfig(
+	ctx context.Context,
+	cli client.Reader,
+	namespace string,
+) (*config.RelevantConfig, error) {
+	if f.userDefinedNetworks {
+		netConfig, err := f.findPrimaryUserDefinedNetworkConfig(ctx, cli, namespace)
+		if err != nil && !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+		if err != nil {
+			logf.FromContext(ctx).V(1).Info(
+				"user defined network CRDs not installed, falling back to NADs",
+				"namespace", namespace,
+			)
+		} else if netConfig != nil {
+			return netConfig, nil
+		}
+	}
+
+	primaryNetworkNAD, err := FindPrimaryNetwork(ctx, cli, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if primaryNetworkNAD == nil {
+		return nil, nil
+	}
+	return f.nadConfigs.Config(primaryNetworkNAD)
+}
+
+// FindPrimaryNetwork returns the namespace primary network NAD; it requires the nads.RoleIndex to be registered.
+// NADs with an invalid configuration are not indexed, thus ignored.
 func FindPrimaryNetwork(ctx context.Context,
-	cli client.Client,
+	cli client.Reader,
 	namespace string) (*v1.NetworkAttachmentDefinition, error) {
 	nadList := v1.NetworkAttachmentDefinitionList{}
-	if err := cli.List(ctx, &nadList, client.InNamespace(namespace)); err != nil {
+	if err := cli.List(
+		ctx,
+		&nadList,
+		client.InNamespace(namespace),
+		client.MatchingFields{nads.RoleIndex: string(config.NetworkRolePrimary)},
+	); err != nil {
 		return nil, fmt.Errorf("failed listing nads for pod namespace %q: %w", namespace, err)
 	}
 
-	for _, nad := range nadList.Items {
-		netConfig, err := config.NewConfig(nad.Spec.Config)
-		if err != nil {
-			return nil, fmt.Errorf("failed to extract the relevant NAD information: %w", err)
-		}
-		if netConfig.Role == config.NetworkRolePrimary {
-			return ptr.To(nad), nil
+	switch len(nadList.Items) {
+	case 0:
+		return nil, nil
+	case 1:
+		return ptr.To(nadList.Items[0]), nil
+	default:
+		var nadNames []string
+		for _, nad := range nadList.Items {
+			nadNames = append(nadNames, nad.Name)
 		}
+		return nil, ambiguousPrimaryNetworkError(namespace, "NADs", nadNames)
 	}
-	return nil, nil
+}
+
+// FindInvalidNADs returns the names of the namespace NADs whose configuration cannot be parsed, which the primary
+// network lookup skips; it requires the nads.InvalidConfigIndex to be registered.
+func FindInvalidNADs(ctx context.Context, cli client.Reader, namespace string) ([]string, error) {
+	nadList := v1.NetworkAttachmentDefinitionList{}
+	if err := cli.List(
+		ctx,
+		&nadList,
+		client.InNamespace(namespace),
+		client.MatchingFields{nads.InvalidConfigIndex: "true"},
+	); err != nil {
+		return nil, fmt.Errorf("failed listing the invalid nads of namespace %q: %w", namespace, err)
+	}
+
+	var nadNames []string
+	for _, nad := range nadList.Items {
+		nadNames = append(nadNames, nad.Name)
+	}
+	sort.Strings(nadNames)
+	return nadNames, nil
+}
+
+func ambiguousPrimaryNetworkError(namespace string, kind string, names []string) error {
+	sort.Strings(names)
+	return fmt.Errorf("%w: namespace %q has several primary network %s %v", ErrAmbiguousPrimaryNetwork,
+		namespace, kind, names)
 }
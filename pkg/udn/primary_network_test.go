@@ -0,0 +1,137 @@
+package udn_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
+)
+
+const (
+	benchmarkNamespace = "ns1"
+	secondaryNADCount  = 50
+)
+
+// BenchmarkFindPrimaryNetwork looks up the primary network using the NAD role index
+func BenchmarkFindPrimaryNetwork(b *testing.B) {
+	reader := newIndexedNADReader(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nad, err := udn.FindPrimaryNetwork(ctx, reader, benchmarkNamespace)
+		if err != nil || nad == nil {
+			b.Fatalf("failed to find the primary network: %v", err)
+		}
+	}
+}
+
+// BenchmarkFindPrimaryNetworkByParsingAllNADs looks up the primary network by listing and parsing every NAD
+func BenchmarkFindPrimaryNetworkByParsingAllNADs(b *testing.B) {
+	reader := newIndexedNADReader(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nad, err := findPrimaryNetworkByParsingAllNADs(ctx, reader, benchmarkNamespace)
+		if err != nil || nad == nil {
+			b.Fatalf("failed to find the primary network: %v", err)
+		}
+	}
+}
+
+func findPrimaryNetworkByParsingAllNADs(
+	ctx context.Context,
+	cli client.Reader,
+	namespace string,
+) (*v1.NetworkAttachmentDefinition, error) {
+	nadList := v1.NetworkAttachmentDefinitionList{}
+	if err := cli.List(ctx, &nadList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range nadList.Items {
+		netConfig, err := config.NewConfig(nadList.Items[i].Spec.Config)
+		if err != nil {
+			return nil, err
+		}
+		if netConfig.Role == config.NetworkRolePrimary {
+			return &nadList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// indexedNADReader lists NADs out of an indexed store, the same way the manager informer cache does
+type indexedNADReader struct {
+	client.Reader
+	indexer toolscache.Indexer
+}
+
+func newIndexedNADReader(b *testing.B) *indexedNADReader {
+	indexers := toolscache.Indexers{toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc}
+	for indexName, extractValue := range nads.NewConfigCache().Indexers() {
+		indexers[indexName] = func(obj interface{}) ([]string, error) {
+			nad := obj.(*v1.NetworkAttachmentDefinition)
+			var keys []string
+			for _, value := range extractValue(nad) {
+				keys = append(keys, nad.Namespace+"/"+value)
+			}
+			return keys, nil
+		}
+	}
+
+	indexer := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, indexers)
+	for i := 0; i < secondaryNADCount; i++ {
+		nad := nadWithConfig(
+			fmt.Sprintf("secondary%d", i),
+			fmt.Sprintf(`{"name": "secondary%d", "allowPersistentIPs": true, "subnets": "10.%d.0.0/16"}`, i, i),
+		)
+		if err := indexer.Add(nad); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := indexer.Add(nadWithConfig("primary", `{"name": "primary", "role": "primary"}`)); err != nil {
+		b.Fatal(err)
+	}
+	return &indexedNADReader{indexer: indexer}
+}
+
+func (r *indexedNADReader) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	var objs []interface{}
+	var err error
+	if listOpts.FieldSelector != nil {
+		requirement := listOpts.FieldSelector.Requirements()[0]
+		objs, err = r.indexer.ByIndex(requirement.Field, listOpts.Namespace+"/"+requirement.Value)
+	} else {
+		objs, err = r.indexer.ByIndex(toolscache.NamespaceIndex, listOpts.Namespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	nadList := list.(*v1.NetworkAttachmentDefinitionList)
+	for _, obj := range objs {
+		nadList.Items = append(nadList.Items, *obj.(*v1.NetworkAttachmentDefinition).DeepCopy())
+	}
+	return nil
+}
+
+func nadWithConfig(name, rawConfig string) *v1.NetworkAttachmentDefinition {
+	return &v1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Namespace: benchmarkNamespace, Name: name, ResourceVersion: "1"},
+		Spec:       v1.NetworkAttachmentDefinitionSpec{Config: rawConfig},
+	}
+}
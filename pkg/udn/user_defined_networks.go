@@ -0,0 +1,268 @@
+package udn
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+)
+
+var (
+	UserDefinedNetworkGVK = schema.GroupVersionKind{
+		Group:   "k8s.ovn.org",
+		Version: "v1",
+		Kind:    "UserDefinedNetwork",
+	}
+	ClusterUserDefinedNetworkGVK = schema.GroupVersionKind{
+		Group:   "k8s.ovn.org",
+		Version: "v1",
+		Kind:    "ClusterUserDefinedNetwork",
+	}
+)
+
+const (
+	// OVN-Kubernetes network name prefix for the networks rendered out of ClusterUserDefinedNetworks
+	clusterUserDefinedNetworkNamePrefix = "cluster_udn_"
+
+	udnIPAMLifecyclePersistent = "Persistent"
+)
+
+// findPrimaryUserDefinedNetworkConfig returns the configuration of the UserDefinedNetwork or
+// ClusterUserDefinedNetwork acting as the namespace primary network; nil when there is none.
+// The user defined networks whose spec cannot be read are reported and ignored, unless they may be the namespace
+// primary network while no other network is.
+// A meta.NoKindMatchError is returned when the OVN-Kubernetes CRDs are not installed.
+func (f *PrimaryNetworkFinder) findPrimaryUserDefinedNetworkConfig(
+	ctx context.Context,
+	cli client.Reader,
+	namespace string,
+) (*config.RelevantConfig, error) {
+	primaryNetworkConfigs, invalidPrimaryNetworks, err := f.primaryUserDefinedNetworkConfigs(ctx, cli, namespace)
+	if err != nil {
+		return nil, err
+	}
+	switch len(primaryNetworkConfigs) {
+	case 0:
+		if len(invalidPrimaryNetworks) > 0 {
+			sort.Strings(invalidPrimaryNetworks)
+			return nil, fmt.Errorf("%w: the primary network of namespace %q may be one of the user defined networks "+
+				"%v, whose spec cannot be read", ErrInvalidUserDefinedNetwork, namespace, invalidPrimaryNetworks)
+		}
+		return nil, nil
+	case 1:
+		return primaryNetworkConfigs[0], nil
+	default:
+		var networkNames []string
+		for _, netConfig := range primaryNetworkConfigs {
+			networkNames = append(networkNames, netConfig.Name)
+		}
+		return nil, ambiguousPrimaryNetworkError(namespace, "user defined networks", networkNames)
+	}
+}
+
+// primaryUserDefinedNetworkConfigs returns the configurations of the namespace primary user defined networks, along
+// with the names of those whose spec cannot be read but may be primary networks of the namespace
+func (f *PrimaryNetworkFinder) primaryUserDefinedNetworkConfigs(
+	ctx context.Context,
+	cli client.Reader,
+	namespace string,
+) ([]*config.RelevantConfig, []string, error) {
+	var (
+		primaryNetworkConfigs  []*config.RelevantConfig
+		invalidPrimaryNetworks []string
+	)
+
+	udnList := &unstructured.UnstructuredList{}
+	udnList.SetGroupVersionKind(listGVK(UserDefinedNetworkGVK))
+	if err := cli.List(ctx, udnList, client.InNamespace(namespace)); err != nil {
+		return nil, nil, fmt.Errorf("failed listing user defined networks for namespace %q: %w", namespace, err)
+	}
+	var invalidUDNs []*unstructured.Unstructured
+	for i := range udnList.Items {
+		udn := &udnList.Items[i]
+		udnSpec, _, err := unstructured.NestedMap(udn.Object, "spec")
+		if err == nil {
+			var netConfig *config.RelevantConfig
+			netConfig, err = userDefinedNetworkConfig(namespace+"_"+udn.GetName(), udnSpec)
+			if err == nil {
+				if netConfig.Role == config.NetworkRolePrimary {
+					primaryNetworkConfigs = append(primaryNetworkConfigs, netConfig)
+				}
+				continue
+			}
+		}
+		f.invalidNetworks.reportOnce(ctx, udn, err)
+		invalidUDNs = append(invalidUDNs, udn)
+		if mayBePrimaryNetwork(udnSpec) {
+			invalidPrimaryNetworks = append(invalidPrimaryNetworks, udn.GetName())
+		}
+	}
+	f.invalidNetworks.forgetFixed(UserDefinedNetworkGVK.Kind, namespace, invalidUDNs)
+
+	cudnList := &unstructured.UnstructuredList{}
+	cudnList.SetGroupVersionKind(listGVK(ClusterUserDefinedNetworkGVK))
+	if err := cli.List(ctx, cudnList); err != nil {
+		return nil, nil, fmt.Errorf("failed listing cluster user defined networks: %w", err)
+	}
+	var (
+		invalidCUDNs    []*unstructured.Unstructured
+		namespaceLabels labels.Set
+	)
+	for i := range cudnList.Items {
+		cudn := &cudnList.Items[i]
+		networkSpec, _, err := unstructured.NestedMap(cudn.Object, "spec", "network")
+		var netConfig *config.RelevantConfig
+		if err == nil {
+			netConfig, err = userDefinedNetworkConfig(clusterUserDefinedNetworkNamePrefix+cudn.GetName(), networkSpec)
+		}
+		if err != nil {
+			f.invalidNetworks.reportOnce(ctx, cudn, err)
+			invalidCUDNs = append(invalidCUDNs, cudn)
+			if !mayBePrimaryNetwork(networkSpec) {
+				continue
+			}
+		} else if netConfig.Role != config.NetworkRolePrimary {
+			continue
+		}
+
+		if namespaceLabels == nil {
+			ns := &corev1.Namespace{}
+			if err := cli.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+				return nil, nil, fmt.Errorf("failed to get namespace %q: %w", namespace, err)
+			}
+			namespaceLabels = labels.Set(ns.Labels)
+		}
+		selectsNamespace, selectorErr := selectsNamespace(*cudn, namespaceLabels)
+		if selectorErr != nil {
+			// the namespaces it selects cannot be told, hence it is not a network the namespace VMs depend on
+			if err == nil {
+				f.invalidNetworks.reportOnce(ctx, cudn, selectorErr)
+				invalidCUDNs = append(invalidCUDNs, cudn)
+			}
+			continue
+		}
+		switch {
+		case !selectsNamespace:
+		case err != nil:
+			invalidPrimaryNetworks = append(invalidPrimaryNetworks, cudn.GetName())
+		default:
+			primaryNetworkConfigs = append(primaryNetworkConfigs, netConfig)
+		}
+	}
+	f.invalidNetworks.forgetFixed(ClusterUserDefinedNetworkGVK.Kind, "", invalidCUDNs)
+	return primaryNetworkConfigs, invalidPrimaryNetworks, nil
+}
+
+// mayBePrimaryNetwork reports whether the user defined network whose spec cannot be read may be a primary network,
+// i.e. its role cannot be read either, or is primary
+func mayBePrimaryNetwork(networkSpec map[string]interface{}) bool {
+	topology, _, err := unstructured.NestedString(networkSpec, "topology")
+	if err != nil {
+		return true
+	}
+	role, _, err := unstructured.NestedString(networkSpec, strings.ToLower(topology), "role")
+	if err != nil {
+		return true
+	}
+	return config.NetworkRole(strings.ToLower(role)) == config.NetworkRolePrimary
+}
+
+func selectsNamespace(cudn unstructured.Unstructured, namespaceLabels labels.Set) (bool, error) {
+	rawSelector, _, err := unstructured.NestedMap(cudn.Object, "spec", "namespaceSelector")
+	if err != nil {
+		return false, fmt.Errorf("failed to read the cluster user defined network %s namespace selector: %w",
+			cudn.GetName(), err)
+	}
+	namespaceSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, namespaceSelector); err != nil {
+		return false, fmt.Errorf("failed to parse the cluster user defined network %s namespace selector: %w",
+			cudn.GetName(), err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(namespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid cluster user defined network %s namespace selector: %w", cudn.GetName(), err)
+	}
+	return selector.Matches(namespaceLabels), nil
+}
+
+// userDefinedNetworkConfig extracts the relevant configuration out of a user defined network spec, i.e.
+// the topology and the matching topology configuration (role, subnets and IPAM lifecycle).
+func userDefinedNetworkConfig(networkName string, networkSpec map[string]interface{}) (*config.RelevantConfig, error) {
+	topology, _, err := unstructured.NestedString(networkSpec, "topology")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the network %q topology: %w", networkName, err)
+	}
+	topologyField := strings.ToLower(topology)
+	topologySpec, _, err := unstructured.NestedMap(networkSpec, topologyField)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the network %q %s configuration: %w", networkName, topology, err)
+	}
+
+	role, _, err := unstructured.NestedString(topologySpec, "role")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the network %q role: %w", networkName, err)
+	}
+	ipamLifecycle, _, err := unstructured.NestedString(topologySpec, "ipam", "lifecycle")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the network %q IPAM lifecycle: %w", networkName, err)
+	}
+	subnets, err := userDefinedNetworkSubnets(topologySpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the network %q subnets: %w", networkName, err)
+	}
+
+	return &config.RelevantConfig{
+		Name:               networkName,
+		AllowPersistentIPs: ipamLifecycle == udnIPAMLifecyclePersistent,
+		Role:               config.NetworkRole(strings.ToLower(role)),
+		Topology:           topologyField,
+		Subnets:            strings.Join(subnets, ","),
+	}, nil
+}
+
+// layer2 subnets are plain CIDRs, while layer3 subnets are objects holding the CIDR and the host subnet prefix
+// length, rendered in the OVN-Kubernetes <cidr>/<host prefix> format
+func userDefinedNetworkSubnets(topologySpec map[string]interface{}) ([]string, error) {
+	rawSubnets, _, err := unstructured.NestedSlice(topologySpec, "subnets")
+	if err != nil {
+		return nil, err
+	}
+	var subnets []string
+	for _, rawSubnet := range rawSubnets {
+		switch subnet := rawSubnet.(type) {
+		case string:
+			subnets = append(subnets, subnet)
+		case map[string]interface{}:
+			cidr, _, err := unstructured.NestedString(subnet, "cidr")
+			if err != nil {
+				return nil, err
+			}
+			hostSubnet, hasHostSubnet, err := unstructured.NestedInt64(subnet, "hostSubnet")
+			if err != nil {
+				return nil, err
+			}
+			if hasHostSubnet {
+				cidr = fmt.Sprintf("%s/%d", cidr, hostSubnet)
+			}
+			subnets = append(subnets, cidr)
+		default:
+			return nil, fmt.Errorf("unexpected subnet %v", rawSubnet)
+		}
+	}
+	return subnets, nil
+}
+
+func listGVK(gvk schema.GroupVersionKind) schema.GroupVersionKind {
+	return gvk.GroupVersion().WithKind(gvk.Kind + "List")
+}
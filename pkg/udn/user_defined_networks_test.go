@@ -0,0 +1,379 @@
+package udn_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	v1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+
+	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
+)
+
+func TestUDN(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "UDN test suite")
+}
+
+type primaryNetworkTestConfig struct {
+	objects               []client.Object
+	withoutUDNCRDs        bool
+	withoutUDNSource      bool
+	expectedNetworkConfig *config.RelevantConfig
+	expectedErr           error
+}
+
+var _ = Describe("Primary network finder", func() {
+	const namespace = "ns1"
+
+	DescribeTable("finds the namespace primary network configuration", func(testConfig primaryNetworkTestConfig) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1.AddToScheme(scheme)).To(Succeed())
+
+		nadConfigs := nads.NewConfigCache()
+		clientBuilder := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(append(testConfig.objects, namespaceWithLabels(namespace, "tenant", "blue"))...)
+		for indexName, extractValue := range nadConfigs.Indexers() {
+			clientBuilder = clientBuilder.WithIndex(&v1.NetworkAttachmentDefinition{}, indexName, extractValue)
+		}
+		if testConfig.withoutUDNCRDs {
+			clientBuilder = clientBuilder.WithInterceptorFuncs(interceptor.Funcs{List: failListingUserDefinedNetworks})
+		}
+
+		var finderOpts []udn.FinderOption
+		if !testConfig.withoutUDNSource {
+			finderOpts = append(finderOpts, udn.WithUserDefinedNetworks())
+		}
+		finder := udn.NewPrimaryNetworkFinder(nadConfigs, finderOpts...)
+
+		netConfig, err := finder.FindPrimaryNetworkConfig(context.Background(), clientBuilder.Build(), namespace)
+		if testConfig.expectedErr != nil {
+			Expect(err).To(MatchError(testConfig.expectedErr))
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(netConfig).To(Equal(testConfig.expectedNetworkConfig))
+	},
+		Entry("from a layer2 primary UserDefinedNetwork with persistent IPAM", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "udn1", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2": map[string]interface{}{
+						"role":    "Primary",
+						"subnets": []interface{}{"10.0.0.0/24", "fd12:1234::/64"},
+						"ipam":    map[string]interface{}{"lifecycle": "Persistent"},
+					},
+				}),
+			},
+			expectedNetworkConfig: &config.RelevantConfig{
+				Name:               "ns1_udn1",
+				Role:               config.NetworkRolePrimary,
+				Topology:           "layer2",
+				AllowPersistentIPs: true,
+				Subnets:            "10.0.0.0/24,fd12:1234::/64",
+			},
+		}),
+		Entry("from a layer3 primary UserDefinedNetwork", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "udn1", map[string]interface{}{
+					"topology": "Layer3",
+					"layer3": map[string]interface{}{
+						"role":    "Primary",
+						"subnets": []interface{}{map[string]interface{}{"cidr": "10.0.0.0/16", "hostSubnet": int64(24)}},
+					},
+				}),
+			},
+			expectedNetworkConfig: &config.RelevantConfig{
+				Name:     "ns1_udn1",
+				Role:     config.NetworkRolePrimary,
+				Topology: config.TopologyLayer3,
+				Subnets:  "10.0.0.0/16/24",
+			},
+		}),
+		Entry("ignoring the secondary UserDefinedNetworks", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "udn1", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Secondary", "subnets": []interface{}{"10.0.0.0/24"}},
+				}),
+			},
+		}),
+		Entry("from a primary ClusterUserDefinedNetwork selecting the namespace", primaryNetworkTestConfig{
+			objects: []client.Object{
+				clusterUserDefinedNetwork("cudn1", "blue", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2": map[string]interface{}{
+						"role":    "Primary",
+						"subnets": []interface{}{"10.0.0.0/24"},
+						"ipam":    map[string]interface{}{"lifecycle": "Persistent"},
+					},
+				}),
+			},
+			expectedNetworkConfig: &config.RelevantConfig{
+				Name:               "cluster_udn_cudn1",
+				Role:               config.NetworkRolePrimary,
+				Topology:           "layer2",
+				AllowPersistentIPs: true,
+				Subnets:            "10.0.0.0/24",
+			},
+		}),
+		Entry("from the primary NAD when no ClusterUserDefinedNetwork selects the namespace", primaryNetworkTestConfig{
+			objects: []client.Object{
+				clusterUserDefinedNetwork("cudn1", "red", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Primary", "subnets": []interface{}{"10.0.0.0/24"}},
+				}),
+				primaryNAD(namespace),
+			},
+			expectedNetworkConfig: primaryNADConfig(),
+		}),
+		Entry("from the primary NAD when the user defined network CRDs are not installed", primaryNetworkTestConfig{
+			objects:               []client.Object{primaryNAD(namespace)},
+			withoutUDNCRDs:        true,
+			expectedNetworkConfig: primaryNADConfig(),
+		}),
+		Entry("from the primary NAD when not reading the user defined networks", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "udn1", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Primary", "subnets": []interface{}{"10.0.0.0/24"}},
+				}),
+				primaryNAD(namespace),
+			},
+			withoutUDNSource:      true,
+			expectedNetworkConfig: primaryNADConfig(),
+		}),
+		Entry("from the primary NAD ignoring the NADs with an invalid configuration", primaryNetworkTestConfig{
+			objects:               []client.Object{nadWithName(namespace, "broken", "{not json}"), primaryNAD(namespace)},
+			withoutUDNSource:      true,
+			expectedNetworkConfig: primaryNADConfig(),
+		}),
+		Entry("ignoring the secondary UserDefinedNetworks whose spec cannot be read", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "broken", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Secondary", "subnets": "10.0.0.0/24"},
+				}),
+				userDefinedNetwork(namespace, "udn1", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Primary", "subnets": []interface{}{"10.1.0.0/24"}},
+				}),
+			},
+			expectedNetworkConfig: &config.RelevantConfig{
+				Name:     "ns1_udn1",
+				Role:     config.NetworkRolePrimary,
+				Topology: "layer2",
+				Subnets:  "10.1.0.0/24",
+			},
+		}),
+		Entry("ignoring the ClusterUserDefinedNetworks whose spec cannot be read not selecting the namespace",
+			primaryNetworkTestConfig{
+				objects: []client.Object{
+					clusterUserDefinedNetwork("broken", "red", map[string]interface{}{
+						"topology": "Layer2",
+						"layer2":   map[string]interface{}{"role": "Primary", "subnets": "10.0.0.0/24"},
+					}),
+					primaryNAD(namespace),
+				},
+				expectedNetworkConfig: primaryNADConfig(),
+			},
+		),
+		Entry("failing when the primary UserDefinedNetwork spec cannot be read", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "broken", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Primary", "subnets": "10.0.0.0/24"},
+				}),
+				primaryNAD(namespace),
+			},
+			expectedErr: udn.ErrInvalidUserDefinedNetwork,
+		}),
+		Entry("failing when the spec of a ClusterUserDefinedNetwork selecting the namespace cannot be read",
+			primaryNetworkTestConfig{
+				objects: []client.Object{
+					clusterUserDefinedNetwork("broken", "blue", map[string]interface{}{"topology": int64(2)}),
+				},
+				expectedErr: udn.ErrInvalidUserDefinedNetwork,
+			},
+		),
+		Entry("failing when several NADs are primary networks", primaryNetworkTestConfig{
+			objects: []client.Object{
+				primaryNAD(namespace),
+				nadWithName(namespace, "otherprimary", `{"name": "otherprimarynet", "role": "primary"}`),
+			},
+			withoutUDNSource: true,
+			expectedErr:      udn.ErrAmbiguousPrimaryNetwork,
+		}),
+		Entry("failing when several user defined networks are primary networks", primaryNetworkTestConfig{
+			objects: []client.Object{
+				userDefinedNetwork(namespace, "udn1", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Primary", "subnets": []interface{}{"10.0.0.0/24"}},
+				}),
+				clusterUserDefinedNetwork("cudn1", "blue", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Primary", "subnets": []interface{}{"10.1.0.0/24"}},
+				}),
+			},
+			expectedErr: udn.ErrAmbiguousPrimaryNetwork,
+		}),
+	)
+})
+
+var _ = Describe("Primary network finder reporting the user defined networks whose spec cannot be read", func() {
+	const namespace = "ns1"
+
+	var (
+		cli      client.Client
+		finder   *udn.PrimaryNetworkFinder
+		reported []string
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1.AddToScheme(scheme)).To(Succeed())
+
+		nadConfigs := nads.NewConfigCache()
+		clientBuilder := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				namespaceWithLabels(namespace, "tenant", "blue"),
+				userDefinedNetwork(namespace, "broken", map[string]interface{}{
+					"topology": "Layer2",
+					"layer2":   map[string]interface{}{"role": "Secondary", "subnets": "10.0.0.0/24"},
+				}),
+			)
+		for indexName, extractValue := range nadConfigs.Indexers() {
+			clientBuilder = clientBuilder.WithIndex(&v1.NetworkAttachmentDefinition{}, indexName, extractValue)
+		}
+		cli = clientBuilder.Build()
+
+		reported = nil
+		finder = udn.NewPrimaryNetworkFinder(nadConfigs,
+			udn.WithUserDefinedNetworks(),
+			udn.WithInvalidNetworkReporter(func(network *unstructured.Unstructured, _ error) {
+				reported = append(reported, network.GetKind()+"/"+network.GetName())
+			}),
+		)
+	})
+
+	lookUpPrimaryNetwork := func() {
+		GinkgoHelper()
+		_, err := finder.FindPrimaryNetworkConfig(context.Background(), cli, namespace)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("reports them once per resourceVersion", func() {
+		lookUpPrimaryNetwork()
+		lookUpPrimaryNetwork()
+		Expect(reported).To(Equal([]string{"UserDefinedNetwork/broken"}))
+
+		brokenUDN := userDefinedNetwork(namespace, "broken", nil)
+		Expect(cli.Get(context.Background(), client.ObjectKeyFromObject(brokenUDN), brokenUDN)).To(Succeed())
+		Expect(unstructured.SetNestedField(brokenUDN.Object, "10.1.0.0/24", "spec", "layer2", "subnets")).To(Succeed())
+		Expect(cli.Update(context.Background(), brokenUDN)).To(Succeed())
+
+		lookUpPrimaryNetwork()
+		Expect(reported).To(Equal([]string{"UserDefinedNetwork/broken", "UserDefinedNetwork/broken"}))
+	})
+
+	It("reports them again once broken after being fixed", func() {
+		lookUpPrimaryNetwork()
+
+		brokenUDN := userDefinedNetwork(namespace, "broken", nil)
+		Expect(cli.Get(context.Background(), client.ObjectKeyFromObject(brokenUDN), brokenUDN)).To(Succeed())
+		Expect(unstructured.SetNestedStringSlice(
+			brokenUDN.Object, []string{"10.1.0.0/24"}, "spec", "layer2", "subnets")).To(Succeed())
+		Expect(cli.Update(context.Background(), brokenUDN)).To(Succeed())
+		lookUpPrimaryNetwork()
+		Expect(reported).To(Equal([]string{"UserDefinedNetwork/broken"}))
+
+		Expect(cli.Delete(context.Background(), brokenUDN)).To(Succeed())
+		brokenUDN = userDefinedNetwork(namespace, "broken", map[string]interface{}{
+			"topology": "Layer2",
+			"layer2":   map[string]interface{}{"role": "Secondary", "subnets": "10.0.0.0/24"},
+		})
+		Expect(cli.Create(context.Background(), brokenUDN)).To(Succeed())
+		lookUpPrimaryNetwork()
+		Expect(reported).To(Equal([]string{"UserDefinedNetwork/broken", "UserDefinedNetwork/broken"}))
+	})
+})
+
+func userDefinedNetwork(namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	udnObj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	udnObj.SetGroupVersionKind(udn.UserDefinedNetworkGVK)
+	udnObj.SetNamespace(namespace)
+	udnObj.SetName(name)
+	return udnObj
+}
+
+func clusterUserDefinedNetwork(name, tenant string, networkSpec map[string]interface{}) *unstructured.Unstructured {
+	cudnObj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"namespaceSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"tenant": tenant},
+			},
+			"network": networkSpec,
+		},
+	}}
+	cudnObj.SetGroupVersionKind(udn.ClusterUserDefinedNetworkGVK)
+	cudnObj.SetName(name)
+	return cudnObj
+}
+
+func namespaceWithLabels(name string, labels ...string) *corev1.Namespace {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		ns.Labels[labels[i]] = labels[i+1]
+	}
+	return ns
+}
+
+func primaryNAD(namespace string) *v1.NetworkAttachmentDefinition {
+	return nadWithName(namespace, "primary", `{"name": "primarynet", "role": "primary", "allowPersistentIPs": true}`)
+}
+
+func nadWithName(namespace, name, rawConfig string) *v1.NetworkAttachmentDefinition {
+	return &v1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       v1.NetworkAttachmentDefinitionSpec{Config: rawConfig},
+	}
+}
+
+func primaryNADConfig() *config.RelevantConfig {
+	return &config.RelevantConfig{Name: "primarynet", Role: config.NetworkRolePrimary, AllowPersistentIPs: true}
+}
+
+func failListingUserDefinedNetworks(
+	ctx context.Context,
+	cli client.WithWatch,
+	list client.ObjectList,
+	opts ...client.ListOption,
+) error {
+	if list.GetObjectKind().GroupVersionKind().Group == udn.UserDefinedNetworkGVK.Group {
+		return &meta.NoKindMatchError{
+			GroupKind:        schema.GroupKind{Group: udn.UserDefinedNetworkGVK.Group, Kind: "UserDefinedNetworkList"},
+			SearchedVersions: []string{udn.UserDefinedNetworkGVK.Version},
+		}
+	}
+	return cli.List(ctx, list, opts...)
+}
@@ -2,16 +2,14 @@ package vminetworkscontroller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 
-	"k8s.io/utils/ptr"
-
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	apitypes "k8s.io/apimachinery/pkg/types"
 
@@ -20,30 +18,38 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
-	ipamclaimsapi "github.com/k8snetworkplumbingwg/ipamclaims/pkg/crd/ipamclaims/v1alpha1"
 	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 
 	virtv1 "kubevirt.io/api/core/v1"
 
 	"github.com/kubevirt/ipam-extensions/pkg/claims"
 	"github.com/kubevirt/ipam-extensions/pkg/config"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
 	"github.com/kubevirt/ipam-extensions/pkg/udn"
 )
 
 // VirtualMachineInstanceReconciler reconciles a VirtualMachineInstance object
 type VirtualMachineInstanceReconciler struct {
 	client.Client
-	Log     logr.Logger
-	Scheme  *runtime.Scheme
-	manager controllerruntime.Manager
+	Log             logr.Logger
+	Scheme          *runtime.Scheme
+	manager         controllerruntime.Manager
+	nadConfigs      *nads.ConfigCache
+	primaryNetworks *udn.PrimaryNetworkFinder
 }
 
-func NewVMIReconciler(manager controllerruntime.Manager) *VirtualMachineInstanceReconciler {
+func NewVMIReconciler(
+	manager controllerruntime.Manager,
+	nadConfigs *nads.ConfigCache,
+	primaryNetworks *udn.PrimaryNetworkFinder,
+) *VirtualMachineInstanceReconciler {
 	return &VirtualMachineInstanceReconciler{
-		Client:  manager.GetClient(),
-		Log:     controllerruntime.Log.WithName("controllers").WithName("VirtualMachineInstance"),
-		Scheme:  manager.GetScheme(),
-		manager: manager,
+		Client:          manager.GetClient(),
+		Log:             controllerruntime.Log.WithName("controllers").WithName("VirtualMachineInstance"),
+		Scheme:          manager.GetScheme(),
+		manager:         manager,
+		nadConfigs:      nadConfigs,
+		primaryNetworks: primaryNetworks,
 	}
 }
 
@@ -83,44 +89,15 @@ func (r *VirtualMachineInstanceReconciler) Reconcile(
 		return controllerruntime.Result{}, err
 	}
 
-	ownerInfo := ownerReferenceFor(vmi, vm)
+	ownerInfo := claims.OwnerReferenceFor(vmi, vm)
 	for logicalNetworkName, netConfigName := range vmiNetworks {
-		claimKey := claims.ComposeKey(vmi.Name, logicalNetworkName)
-		ipamClaim := &ipamclaimsapi.IPAMClaim{
-			ObjectMeta: controllerruntime.ObjectMeta{
-				Name:            claimKey,
-				Namespace:       vmi.Namespace,
-				OwnerReferences: []metav1.OwnerReference{ownerInfo},
-				Finalizers:      []string{claims.KubevirtVMFinalizer},
-				Labels:          claims.OwnedByVMLabel(vmi.Name),
-			},
-			Spec: ipamclaimsapi.IPAMClaimSpec{
-				Network: netConfigName,
-			},
-		}
-
-		if err := r.Create(ctx, ipamClaim, &client.CreateOptions{}); err != nil {
-			if apierrors.IsAlreadyExists(err) {
-				claimKey := apitypes.NamespacedName{
-					Namespace: vmi.Namespace,
-					Name:      claimKey,
-				}
-
-				existingIPAMClaim := &ipamclaimsapi.IPAMClaim{}
-				if err := r.Get(ctx, claimKey, existingIPAMClaim); err != nil {
-					return controllerruntime.Result{}, fmt.Errorf("let us be on the safe side and retry later")
-				}
-
-				if len(existingIPAMClaim.OwnerReferences) == 1 && existingIPAMClaim.OwnerReferences[0].UID == ownerInfo.UID {
-					r.Log.V(1).Info("found existing IPAMClaim belonging to this VM/VMI, nothing to do", "UID", ownerInfo.UID)
-					continue
-				} else {
-					err := fmt.Errorf("failed since it found an existing IPAMClaim for %q", claimKey.Name)
-					r.Log.Error(err, "leaked IPAMClaim found", "existing owner", existingIPAMClaim.UID)
-					return controllerruntime.Result{}, err
-				}
+		ipamClaim := claims.NewIPAMClaim(vmi, ownerInfo, logicalNetworkName, netConfigName)
+		if err := claims.Ensure(ctx, r.Client, r.Client, ipamClaim); err != nil {
+			if errors.Is(err, claims.ErrLeakedIPAMClaim) {
+				r.Log.Error(err, "leaked IPAMClaim found", "owner UID", ownerInfo.UID)
+			} else {
+				r.Log.Error(err, "failed to create the IPAMClaim")
 			}
-			r.Log.Error(err, "failed to create the IPAMClaim")
 			return controllerruntime.Result{}, err
 		}
 	}
@@ -159,7 +136,7 @@ func (r *VirtualMachineInstanceReconciler) vmiNetworksClaimingIPAM(
 ) (map[string]string, error) {
 	vmiNets := make(map[string]string)
 	for _, net := range vmi.Spec.Networks {
-		if net.Multus != nil && !net.Multus.Default {
+		if net.Multus != nil {
 			if err := r.ensureVMINetworksWithSecondaryUDN(ctx, vmi.Namespace, net, vmiNets); err != nil {
 				return nil, err
 			}
@@ -194,33 +171,37 @@ func (r *VirtualMachineInstanceReconciler) ensureVMINetworksWithSecondaryUDN(ctx
 			return err
 		}
 	}
-	return r.ensureVMINetworkWithUDN(network, nad, vmiNets)
+	return r.ensureVMINetworkWithNAD(network, nad, vmiNets)
 }
 
 func (r *VirtualMachineInstanceReconciler) ensureVMINetworksWithPrimaryUDN(ctx context.Context,
 	namespace string, network virtv1.Network, vmiNets map[string]string) error {
-	primaryNetworkNAD, err := udn.FindPrimaryNetwork(ctx, r.Client, namespace)
+	primaryNetworkConfig, err := r.primaryNetworks.FindPrimaryNetworkConfig(ctx, r.Client, namespace)
 	if err != nil {
 		return err
 	}
-	if primaryNetworkNAD == nil {
+	if primaryNetworkConfig == nil {
 		return nil
 	}
-	return r.ensureVMINetworkWithUDN(network, primaryNetworkNAD, vmiNets)
+	ensureVMINetworkWithUDN(network, primaryNetworkConfig, vmiNets)
+	return nil
 }
 
-func (r *VirtualMachineInstanceReconciler) ensureVMINetworkWithUDN(network virtv1.Network,
+func (r *VirtualMachineInstanceReconciler) ensureVMINetworkWithNAD(network virtv1.Network,
 	nad *nadv1.NetworkAttachmentDefinition, vmiNets map[string]string) error {
-	nadConfig, err := config.NewConfig(nad.Spec.Config)
+	nadConfig, err := r.nadConfigs.Config(nad)
 	if err != nil {
 		r.Log.Error(err, "failed extracting the relevant NAD configuration", "NAD name", nad.Name)
-		return fmt.Errorf("failed to extract the relevant NAD information")
+		return fmt.Errorf("failed to extract the relevant NAD information: %w", err)
 	}
+	ensureVMINetworkWithUDN(network, nadConfig, vmiNets)
+	return nil
+}
 
-	if nadConfig.AllowPersistentIPs {
-		vmiNets[network.Name] = nadConfig.Name
+func ensureVMINetworkWithUDN(network virtv1.Network, netConfig *config.RelevantConfig, vmiNets map[string]string) {
+	if netConfig.AllowPersistentIPs {
+		vmiNets[network.Name] = netConfig.Name
 	}
-	return nil
 }
 
 func shouldCleanFinalizers(vmi *virtv1.VirtualMachineInstance, vm *virtv1.VirtualMachine) bool {
@@ -233,25 +214,6 @@ func shouldCleanFinalizers(vmi *virtv1.VirtualMachineInstance, vm *virtv1.Virtua
 	}
 }
 
-func ownerReferenceFor(vmi *virtv1.VirtualMachineInstance, vm *virtv1.VirtualMachine) metav1.OwnerReference {
-	var obj client.Object
-	if vm != nil {
-		obj = vm
-	} else {
-		obj = vmi
-	}
-
-	aPIVersion := obj.GetObjectKind().GroupVersionKind().Group + "/" + obj.GetObjectKind().GroupVersionKind().Version
-	return metav1.OwnerReference{
-		APIVersion:         aPIVersion,
-		Kind:               obj.GetObjectKind().GroupVersionKind().Kind,
-		Name:               obj.GetName(),
-		UID:                obj.GetUID(),
-		Controller:         ptr.To(true),
-		BlockOwnerDeletion: ptr.To(true),
-	}
-}
-
 // Gets the owning VM if any. for simplicity it just try to fetch the VM,
 // even when the VMI exists, instead of parsing ownerReferences and handling differently the nil VMI case.
 func getOwningVM(ctx context.Context, c client.Client, name apitypes.NamespacedName) (*virtv1.VirtualMachine, error) {
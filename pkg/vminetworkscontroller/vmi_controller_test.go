@@ -31,6 +31,8 @@ import (
 	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 
 	"github.com/kubevirt/ipam-extensions/pkg/claims"
+	"github.com/kubevirt/ipam-extensions/pkg/nads"
+	"github.com/kubevirt/ipam-extensions/pkg/udn"
 )
 
 func TestController(t *testing.T) {
@@ -110,10 +112,11 @@ var _ = Describe("VMI IPAM controller", Serial, func() {
 			}
 		}
 
+		nadConfigs := nads.NewConfigCache()
 		ctrlOptions := controllerruntime.Options{
 			Scheme: scheme.Scheme,
 			NewClient: func(_ *rest.Config, _ client.Options) (client.Client, error) {
-				return fake.NewClientBuilder().
+				return withNADIndexes(fake.NewClientBuilder(), nadConfigs).
 					WithScheme(scheme.Scheme).
 					WithObjects(initialObjects...).
 					Build(), nil
@@ -123,7 +126,7 @@ var _ = Describe("VMI IPAM controller", Serial, func() {
 		mgr, err := controllerruntime.NewManager(&rest.Config{}, ctrlOptions)
 		Expect(err).NotTo(HaveOccurred())
 
-		vmiReconciler := NewVMIReconciler(mgr)
+		vmiReconciler := NewVMIReconciler(mgr, nadConfigs, udn.NewPrimaryNetworkFinder(nadConfigs))
 		if config.expectedError != nil {
 			_, err := vmiReconciler.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: vmiKey})
 			Expect(err).To(MatchError(config.expectedError.Error()))
@@ -183,15 +186,84 @@ var _ = Describe("VMI IPAM controller", Serial, func() {
 				},
 			},
 		}),
-		Entry("when the VM has an associated VMI pointing to an existing NAD but as multus default network", testConfig{
+		Entry("when the VM has an associated VMI with two networks attached to the same NAD", testConfig{
+			inputVM:  dummyVM(dummyVMISpecWithSecondaryNetworks(nadName, "random_net", "bond_net")),
+			inputVMI: dummyVMI(dummyVMISpecWithSecondaryNetworks(nadName, "random_net", "bond_net")),
+			inputNADs: []*nadv1.NetworkAttachmentDefinition{
+				dummyNAD(nadName),
+			},
+			expectedResponse: reconcile.Result{},
+			expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:       claims.ComposeKey(vmName, "random_net"),
+						Namespace:  namespace,
+						Finalizers: []string{claims.KubevirtVMFinalizer},
+						Labels:     claims.OwnedByVMLabel(vmName),
+						OwnerReferences: []metav1.OwnerReference{{
+							APIVersion:         "kubevirt.io/v1",
+							Kind:               "VirtualMachine",
+							Name:               vmName,
+							Controller:         ptr.To(true),
+							BlockOwnerDeletion: ptr.To(true)},
+						},
+					},
+					Spec: ipamclaimsapi.IPAMClaimSpec{Network: "goodnet"},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:       claims.ComposeKey(vmName, "bond_net"),
+						Namespace:  namespace,
+						Finalizers: []string{claims.KubevirtVMFinalizer},
+						Labels:     claims.OwnedByVMLabel(vmName),
+						OwnerReferences: []metav1.OwnerReference{{
+							APIVersion:         "kubevirt.io/v1",
+							Kind:               "VirtualMachine",
+							Name:               vmName,
+							Controller:         ptr.To(true),
+							BlockOwnerDeletion: ptr.To(true)},
+						},
+					},
+					Spec: ipamclaimsapi.IPAMClaimSpec{Network: "goodnet"},
+				},
+			},
+		}),
+		Entry("when the VM has an associated VMI pointing to an existing NAD as multus default network", testConfig{
 			inputVM:  dummyVM(dummyVMIWithMultusDefaultNetworkSpec(nadName)),
 			inputVMI: dummyVMI(dummyVMIWithMultusDefaultNetworkSpec(nadName)),
 			inputNADs: []*nadv1.NetworkAttachmentDefinition{
 				dummyNAD(nadName),
 			},
-			expectedResponse:   reconcile.Result{},
-			expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{},
+			expectedResponse: reconcile.Result{},
+			expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:       claims.ComposeKey(vmName, "default_multus"),
+						Namespace:  namespace,
+						Finalizers: []string{claims.KubevirtVMFinalizer},
+						Labels:     claims.OwnedByVMLabel(vmName),
+						OwnerReferences: []metav1.OwnerReference{{
+							APIVersion:         "kubevirt.io/v1",
+							Kind:               "VirtualMachine",
+							Name:               vmName,
+							Controller:         ptr.To(true),
+							BlockOwnerDeletion: ptr.To(true)},
+						},
+					},
+					Spec: ipamclaimsapi.IPAMClaimSpec{Network: "goodnet"},
+				},
+			},
 		}),
+		Entry("when the VM has an associated VMI pointing to a NAD without persistent IPs as multus default network",
+			testConfig{
+				inputVM:  dummyVM(dummyVMIWithMultusDefaultNetworkSpec(nadName)),
+				inputVMI: dummyVMI(dummyVMIWithMultusDefaultNetworkSpec(nadName)),
+				inputNADs: []*nadv1.NetworkAttachmentDefinition{
+					dummyNADWithConfig(nadName, `{"name": "goodnet"}`),
+				},
+				expectedResponse:   reconcile.Result{},
+				expectedIPAMClaims: []ipamclaimsapi.IPAMClaim{},
+			}),
 		Entry("when the VM has an associated VMI pointing to an existing NAD with an improper config", testConfig{
 			inputVM:  dummyVM(dummyVMISpec(nadName)),
 			inputVMI: dummyVMI(dummyVMISpec(nadName)),
@@ -519,6 +591,21 @@ func dummyVMISpec(nadName string) virtv1.VirtualMachineInstanceSpec {
 	}
 }
 
+func dummyVMISpecWithSecondaryNetworks(nadName string, networkNames ...string) virtv1.VirtualMachineInstanceSpec {
+	vmiSpec := virtv1.VirtualMachineInstanceSpec{}
+	for _, networkName := range networkNames {
+		vmiSpec.Networks = append(vmiSpec.Networks, virtv1.Network{
+			Name: networkName,
+			NetworkSource: virtv1.NetworkSource{
+				Multus: &virtv1.MultusNetwork{
+					NetworkName: nadName,
+				},
+			},
+		})
+	}
+	return vmiSpec
+}
+
 func dummyVMIWithMultusDefaultNetworkSpec(nadName string) virtv1.VirtualMachineInstanceSpec {
 	return virtv1.VirtualMachineInstanceSpec{
 		Networks: []virtv1.Network{
@@ -675,3 +762,10 @@ var _ = Describe("shouldCleanFinalizers", func() {
 		),
 	)
 })
+
+func withNADIndexes(clientBuilder *fake.ClientBuilder, nadConfigs *nads.ConfigCache) *fake.ClientBuilder {
+	for indexName, extractValue := range nadConfigs.Indexers() {
+		clientBuilder = clientBuilder.WithIndex(&nadv1.NetworkAttachmentDefinition{}, indexName, extractValue)
+	}
+	return clientBuilder
+}